@@ -31,7 +31,7 @@ func waitQuit() {
 func main() {
 	flag.Parse()
 
-	conf := &finder.Config{}
+	conf := finder.NewConfig()
 	if err := config.Load(*configFile, conf); err != nil {
 		log.Panic(err)
 	}
@@ -41,6 +41,14 @@ func main() {
 	srv := finder.New(
 		finder.WithLog(conf.Log.FilePath, conf.Log.MaxAgeHours, conf.Log.MaxRotationMegabytes),
 		finder.WithServer(conf.Server.Address, conf.Server.Port),
+		finder.WithStorage(conf.Storage.Driver, conf.Storage.Path, conf.Storage.Backups),
+		finder.WithIIDXVersions(conf.IIDX.VersionFile, conf.IIDX.Versions),
+		finder.WithIIDXSimilarity(conf.IIDX.SimilarityThreshold),
+		finder.WithSDVXSimilarity(conf.SDVX.SimilarityThreshold),
+		finder.WithSDVXRegistry(conf.SDVX.Versions, conf.SDVX.Infinite, conf.SDVX.LevelMapper),
+		finder.WithSDVXFixup(conf.SDVX.Fixup),
+		finder.WithSDVXMusicDB(conf.SDVX.MusicDB, conf.SDVX.Precedence),
+		finder.WithNormalize(conf.Search),
 	)
 
 	log.Printf("finder %s running...%v", version, srv.Start())
@@ -1,13 +1,13 @@
 module finder
 
-go 1.20
+go 1.23.0
 
 require (
 	github.com/BurntSushi/toml v1.4.0
-	github.com/clbanning/mxj/v2 v2.7.0
 	github.com/gin-gonic/gin v1.10.0
 	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
 	github.com/mattn/go-sqlite3 v1.14.24
+	github.com/mozillazg/go-pinyin v0.21.0
 	golang.org/x/text v0.23.0
 )
 
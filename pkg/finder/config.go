@@ -1,6 +1,7 @@
 package finder
 
 import (
+	"finder/pkg/search"
 	"log"
 	"os"
 	"os/exec"
@@ -21,6 +22,41 @@ type Config struct {
 		Address string //服务器地址
 		Port    uint   //端口
 	}
+
+	//Storage 外号和别名存储
+	Storage struct {
+		Driver  string //存储驱动 json/sqlite, 默认json
+		Path    string //sqlite数据库路径, 默认finder.db
+		Backups int    //json文件保留的备份份数, 默认5, 负数不备份
+	}
+
+	//IIDX IIDX相关
+	IIDX struct {
+		VersionFile         string            //版本名表文件(json/toml, 版本号->版本名), 可选
+		Versions            map[string]string //版本号->版本名, 覆盖内置表和文件中的同名项
+		SimilarityThreshold float64           //曲师/曲风搜索的相似度匹配阈值(0~1), 默认0.5
+	}
+
+	//SDVX SDVX相关
+	SDVX struct {
+		SimilarityThreshold float64           //相似度匹配阈值(0~1), 默认0.5
+		MusicDB             []string          //按顺序叠加的数据库文件, 默认music_db.xml
+		Precedence          string            //同一id的取舍规则 override(后面覆盖前面, 默认)/keep(保留先出现的)
+		Versions            map[string]string //版本号->版本名, 覆盖内置表
+		Infinite            map[string]string //第4难度版本(inf_ver)->难度简写, 覆盖内置表
+		LevelMapper         map[string]string //难度简写->music_db中的难度标签, 覆盖内置表
+		Fixup               map[string]string //替代字->实际字符, 覆盖内置表, 为空时去掉该项
+	}
+
+	//Search 匹配歌名/外号/别名时的归一化, 默认全部开启
+	Search search.NormalizeOptions
+}
+
+// NewConfig 带默认值的配置, toml中没写的项保持默认
+func NewConfig() *Config {
+	conf := &Config{}
+	conf.Search = search.DefaultNormalizeOptions()
+	return conf
 }
 
 func FullPath() string {
@@ -35,4 +71,4 @@ func FullPath() string {
 	}
 
 	return path[0:strings.LastIndex(path, string(os.PathSeparator))] + string(os.PathSeparator)
-}
\ No newline at end of file
+}
@@ -2,13 +2,16 @@ package finder
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sync"
+	"sort"
+
+	"finder/pkg/search"
+	"finder/pkg/storage"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type MusicInfo struct {
@@ -16,8 +19,9 @@ type MusicInfo struct {
 }
 
 type MusicDataInfo struct {
-	Title   string `json:"title"`
-	Version uint   `json:"version"`
+	Title       string `json:"title"`
+	Version     uint   `json:"version"`
+	VersionName string `json:"versionName"` // 版本名, 加载时按版本名表填写
 
 	AsciiTitle string `json:"asciiTitle"`
 	Genre      string `json:"genre"`
@@ -40,6 +44,145 @@ type NickInfo struct {
 	Nick map[string]uint `json:"nicks"`
 }
 
+// 索引字段
+const (
+	fieldTitle       = "title"
+	fieldNick        = "nick"
+	fieldAlias       = "alias"
+	fieldMID         = "mid"
+	fieldArtist      = "artist"
+	fieldGenre       = "genre"
+	fieldTitleRomaji = "title_romaji"   // 曲名中假名的罗马音
+	fieldAsciiTitle  = "ascii_title"    // IIDX AsciiTitle
+	fieldYomigana    = "title_yomigana" // SDVX 曲名读音(按罗马音索引)
+	fieldAscii       = "ascii"          // SDVX ascii曲名
+	fieldIllustrator = "illustrator"    // SDVX 曲绘画师
+	fieldEffector    = "effected_by"    // SDVX 谱师
+)
+
+// defaultNormalize 默认的归一化函数
+var defaultNormalize = search.NewNormalizer(search.DefaultNormalizeOptions())
+
+var (
+	errNickExists   = errors.New("id was exists")
+	errMIDNotExists = errors.New("id was not exists")
+)
+
+// iidxCatalog IIDX歌库快照
+// 构建完成后只读, 重载/修改外号时整体替换
+type iidxCatalog struct {
+	mid  map[uint]string //uint,string
+	name map[string]uint //string,uint
+	nick map[string]uint //string,uint
+
+	music map[uint]MusicDataInfo //uint,MInfo
+
+	genre   map[string][]MusicDataInfo //string,[]MInfo
+	artist  map[string][]MusicDataInfo //string,[]MInfo
+	version map[uint][]MusicDataInfo   //uint,[]MInfo
+
+	fold   func(string) string // 归一化函数
+	songs  *search.Index       // 歌名索引
+	nicks  *search.Index       // 外号索引
+	romaji *search.Index       // AsciiTitle和曲名罗马音索引
+
+	nickPinyin *search.Index // 外号拼音索引
+
+	artists *iidxGroupIndex // 曲师索引
+	genres  *iidxGroupIndex // 曲风索引
+
+	charts []IIDXChart // 全部谱面(按等级排列)
+}
+
+func newIIDXCatalog(fold func(string) string) *iidxCatalog {
+	return &iidxCatalog{
+		fold:   fold,
+		mid:    make(map[uint]string),
+		name:   make(map[string]uint),
+		nick:   make(map[string]uint),
+		music:  make(map[uint]MusicDataInfo),
+		genre:  make(map[string][]MusicDataInfo),
+		artist: make(map[string][]MusicDataInfo),
+
+		version: make(map[uint][]MusicDataInfo),
+	}
+}
+
+// validate 校验快照是否可用
+func (c *iidxCatalog) validate() error {
+	if len(c.mid) == 0 {
+		return fmt.Errorf("iidx catalog is empty")
+	}
+	return nil
+}
+
+// build 建立全部索引(歌名/外号/曲师/曲风/谱面)
+func (c *iidxCatalog) build() {
+	c.buildSongIndex()
+	c.buildNickIndex()
+	c.artists = newIIDXGroupIndex(fieldArtist, c.artist, c.fold)
+	c.genres = newIIDXGroupIndex(fieldGenre, c.genre, c.fold)
+	c.buildCharts()
+}
+
+// buildSongIndex 建立歌名索引和罗马音索引(按MID顺序)
+func (c *iidxCatalog) buildSongIndex() {
+	mids := make([]uint, 0, len(c.mid))
+	for mid := range c.mid {
+		mids = append(mids, mid)
+	}
+	sort.Slice(mids, func(i, j int) bool { return mids[i] < mids[j] })
+
+	builder := search.NewBuilder(c.fold)
+	romaji := search.NewBuilder(c.fold)
+	for _, mid := range mids {
+		builder.Add(int64(mid), fieldTitle, c.mid[mid])
+		romaji.Add(int64(mid), fieldAsciiTitle, c.music[mid].AsciiTitle)
+		romaji.AddRomaji(int64(mid), fieldTitleRomaji, c.mid[mid])
+	}
+	c.songs = builder.Build()
+	c.romaji = romaji.Build()
+}
+
+// buildNickIndex 建立外号索引和拼音索引(按外号顺序)
+func (c *iidxCatalog) buildNickIndex() {
+	nicks := make([]string, 0, len(c.nick))
+	for nick := range c.nick {
+		nicks = append(nicks, nick)
+	}
+	sort.Strings(nicks)
+
+	builder := search.NewBuilder(c.fold)
+	pinyin := search.NewBuilder(c.fold)
+	for _, nick := range nicks {
+		builder.Add(int64(c.nick[nick]), fieldNick, nick)
+		pinyin.AddPinyin(int64(c.nick[nick]), nick)
+	}
+	c.nicks = builder.Build()
+	c.nickPinyin = pinyin.Build()
+}
+
+// song 通过MID获取完整歌曲信息
+func (c *iidxCatalog) song(mid uint) (*MusicDataInfo, error) {
+	music, ok := c.music[mid]
+	if !ok {
+		return nil, fmt.Errorf("music info with mid %d not found", mid)
+	}
+	return &music, nil
+}
+
+// withNick 复制一份外号表, 修改后返回新快照(歌库部分共享)
+func (c *iidxCatalog) withNick(modify func(nick map[string]uint)) *iidxCatalog {
+	next := *c
+	next.nick = make(map[string]uint, len(c.nick))
+	for nick, mid := range c.nick {
+		next.nick[nick] = mid
+	}
+	modify(next.nick)
+	next.buildNickIndex()
+	return &next
+}
+
 func New(opts ...Options) (f *Finder) {
 	f = &Finder{}
 
@@ -51,6 +194,10 @@ func New(opts ...Options) (f *Finder) {
 }
 
 func (f *Finder) Start() error {
+	if e := f.openStore(); e != nil {
+		return e
+	}
+
 	if e := f.reload(); e != nil {
 		return e
 	}
@@ -85,10 +232,7 @@ func (f *Finder) Start() error {
 }
 
 // 加载歌库
-func (f *Finder) loadMusicDB(path string) error {
-	f.m.RLock()
-	defer f.m.RUnlock()
-
+func (f *Finder) loadMusicDB(c *iidxCatalog, path string) error {
 	jsonBytes, err := os.ReadFile(path)
 	if err != nil {
 		return err
@@ -103,91 +247,167 @@ func (f *Finder) loadMusicDB(path string) error {
 	counts := 0
 	for _, data := range musics.Data {
 		for mid, music := range data {
-			f.mid.Store(mid, music.Title)
-			f.name.Store(music.Title, mid)
-			f.genre[music.Genre] = append(f.genre[music.Genre], music)
-			f.artist[music.Artist] = append(f.genre[music.Artist], music)
+			music.MID = mid // 以歌库的key为准
+			music.VersionName = f.iidxVersionName(music.Version)
+			c.mid[mid] = music.Title
+			c.name[music.Title] = mid
+			c.music[mid] = music
+			c.genre[music.Genre] = append(c.genre[music.Genre], music)
+			c.artist[music.Artist] = append(c.artist[music.Artist], music)
+			c.version[music.Version] = append(c.version[music.Version], music)
 			counts++
 		}
 	}
 
 	f.logln("load total db musics:", counts)
-	f.logln("load total db artist:", len(f.artist))
-	f.logln("load total db genre:", len(f.genre))
+	f.logln("load total db artist:", len(c.artist))
+	f.logln("load total db genre:", len(c.genre))
+	f.logln("load total db version:", len(c.version))
 
 	return nil
 }
 
-// 加载外号
-func (f *Finder) loadNickName(path string) error {
-	f.m.RLock()
-	defer f.m.RUnlock()
+// openStore 按配置打开外号和别名的存储
+func (f *Finder) openStore() error {
+	if f.store != nil {
+		return nil
+	}
 
-	jsonBytes, err := os.ReadFile(path)
+	dbPath := f.storagePath
+	if dbPath == "" {
+		dbPath = "finder.db"
+	}
+	if !filepath.IsAbs(dbPath) {
+		dbPath = filepath.Join(FullPath(), dbPath)
+	}
+
+	backups := f.storageBackups
+	if backups == 0 {
+		backups = 5
+	}
+
+	store, err := storage.Open(f.storageDriver, storage.Options{
+		NickPath:  filepath.Join(FullPath(), "music_nick.json"),
+		AliasPath: "aliases.json",
+		DBPath:    dbPath,
+		Backups:   backups,
+		Logln:     f.logln,
+	})
 	if err != nil {
 		return err
 	}
 
-	nick := make(map[string]uint)
-	err = json.Unmarshal(jsonBytes, &nick)
+	f.logln("storage driver:", f.storageDriver)
+	f.store = store
+	return nil
+}
+
+// 加载外号
+func (f *Finder) loadNickName(c *iidxCatalog) error {
+	nick, err := f.store.LoadNicks()
 	if err != nil {
 		return err
 	}
 
-	counts := 0
+	dangling := 0
 	for nick, mid := range nick {
-		f.nick.Store(nick, mid)
-		counts++
+		if _, ok := c.mid[mid]; !ok {
+			dangling++
+		}
+		c.nick[nick] = mid
 	}
 
-	f.logln("load total nicks:", counts)
+	f.logln("load total nicks:", len(c.nick))
+	if dangling > 0 {
+		f.logln("nicks bound to unknown mid:", dangling)
+	}
 
 	return nil
 }
 
 // 写入外号
-func (f *Finder) saveNickName(path string) error {
+// 调用方需持有f.m
+func (f *Finder) saveNickName(c *iidxCatalog, nick string) error {
+	f.logln("save total nicks:", len(c.nick))
+
+	return f.store.SaveNick(c.nick, nick)
+}
+
+// catalog 当前IIDX歌库快照
+func (f *Finder) catalog() *iidxCatalog {
+	if c := f.iidx.Load(); c != nil {
+		return c
+	}
+	return newIIDXCatalog(f.normalizer())
+}
+
+// reload 从程序目录重新加载
+func (f *Finder) reload() error {
+	return f.reloadDir(FullPath())
+}
+
+// reloadDir 用dir中的music_data.json在旁路构建完整快照, 校验通过后一次性替换
+// 失败时继续使用旧快照
+func (f *Finder) reloadDir(dir string) error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	m := make(map[string]uint)
-	f.nick.Range(func(nick, mid any) bool {
-		m[nick.(string)] = mid.(uint)
-		return true
-	})
+	c := newIIDXCatalog(f.normalizer())
 
-	bytes, err := json.Marshal(m)
+	if err := f.loadMusicDB(c, filepath.Join(dir, "music_data.json")); err != nil {
+		f.logln("reload iidx failed, keep old catalog:", err)
+		return err
+	}
 
-	if err != nil {
+	if err := f.loadNickName(c); err != nil {
+		f.logln("reload iidx failed, keep old catalog:", err)
 		return err
 	}
 
-	f.logln("save total nicks:", len(m))
+	if err := c.validate(); err != nil {
+		f.logln("reload iidx failed, keep old catalog:", err)
+		return err
+	}
 
-	return os.WriteFile(path, bytes, 0755)
+	c.build()
+
+	f.iidx.Store(c)
+	return nil
 }
 
-func (f *Finder) reload() error {
-	f.nick = sync.Map{}
-	f.name = sync.Map{}
-	f.mid = sync.Map{}
-	f.genre = make(map[string][]MusicDataInfo)
-	f.artist = make(map[string][]MusicDataInfo)
+// updateNick 以写时复制的方式修改外号nick并落盘
+func (f *Finder) updateNick(nick string, modify func(c *iidxCatalog, nicks map[string]uint) error) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	old := f.catalog()
+
+	var err error
+	next := old.withNick(func(nicks map[string]uint) {
+		err = modify(old, nicks)
+	})
+	if err != nil {
+		return err
+	}
 
-	if err := f.loadMusicDB(filepath.Join(FullPath(), "music_data.json")); err != nil {
+	// 先落盘, 成功后再替换快照, 失败时内存和文件保持一致
+	if err := f.saveNickName(next, nick); err != nil {
 		return err
 	}
 
-	return f.loadNickName(filepath.Join(FullPath(), "music_nick.json"))
+	f.iidx.Store(next)
+	return nil
 }
 
 func (f *Finder) sdvxLoadUni() error {
 	// SDVXLoad
-	if e := f.SDVXManager.LoadData("music_db.xml"); e != nil {
+	if e := f.SDVXManager.LoadData(f.SDVXManager.musicDBFiles()...); e != nil {
+		f.logln("reload sdvx db failed, keep old catalog:", e)
 		return e
 	}
 
-	if e := f.SDVXManager.LoadAliases("aliases.json"); e != nil {
+	if e := f.SDVXManager.LoadAliasStore(f.store); e != nil {
+		f.logln("reload sdvx aliases failed, keep old aliases:", e)
 		return e
 	}
 
@@ -0,0 +1,71 @@
+package finder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"finder/pkg/storage"
+)
+
+// failingNickStore 写入总是失败的外号存储
+type failingNickStore struct {
+	storage.Store
+}
+
+func (failingNickStore) SaveNick(map[string]uint, string) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestUpdateNickSaveFailureKeepsSnapshot(t *testing.T) {
+	f := &Finder{store: failingNickStore{}}
+	f.iidx.Store(newTestIIDXCatalog(t))
+
+	err := f.updateNick("新外号", func(_ *iidxCatalog, nicks map[string]uint) error {
+		nicks["新外号"] = 30053
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected save error")
+	}
+
+	// 保存失败时内存中的外号不变
+	if _, ok := f.catalog().nick["新外号"]; ok {
+		t.Error("failed save should not publish the nick")
+	}
+}
+
+func TestReloadKeepsOldCatalog(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "music_data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "music_data.json")
+	if err := os.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "music_nick.json"), []byte(`{"罪过的圣堂": 30053}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Finder{store: storage.NewJSON(filepath.Join(dir, "music_nick.json"), filepath.Join(dir, "aliases.json"))}
+	if err := f.reloadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// 损坏的文件和空歌库都不替换旧快照
+	for _, bad := range []string{`{"data": [`, `{"data": []}`} {
+		if err := os.WriteFile(dbPath, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.reloadDir(dir); err == nil {
+			t.Errorf("%s: expected reload error", bad)
+		}
+		if _, err := f.catalog().song(30053); err != nil {
+			t.Errorf("%s: old catalog should keep serving: %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package finder
+
+import (
+	"sort"
+
+	"finder/pkg/search"
+)
+
+// Group 按名称分组的曲师/曲风/曲绘画师/谱师
+type Group struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count"`           // 名称下的歌曲数或谱面数
+	Score float64 `json:"score,omitempty"` // 搜索时的匹配分数 0~1
+}
+
+// groupIndex 名称 -> 歌曲/谱面的分组索引
+// 索引的文档id为名称在names中的下标
+type groupIndex[T any] struct {
+	field string
+	names []string
+	items map[string][]T
+	index *search.Index
+}
+
+// newGroupIndex 按名称排序建立索引, 每个名称下的顺序保持不变
+func newGroupIndex[T any](field string, items map[string][]T, fold func(string) string) *groupIndex[T] {
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	builder := search.NewBuilder(fold)
+	for i, name := range names {
+		builder.Add(int64(i), field, name)
+	}
+
+	return &groupIndex[T]{field: field, names: names, items: items, index: builder.Build()}
+}
+
+// list 全部名称(按名称排列)
+func (g *groupIndex[T]) list() []Group {
+	groups := make([]Group, 0, len(g.names))
+	for _, name := range g.names {
+		groups = append(groups, Group{Name: name, Count: len(g.items[name])})
+	}
+	return groups
+}
+
+// search 模糊搜索名称, 与歌名/曲名使用同一个归一化
+// 先按包含匹配, 匹配不到时按相似度(不低于threshold)容错, 按分数从高到低排列
+func (g *groupIndex[T]) search(query string, threshold float64) []Group {
+	folded := g.index.Fold(query)
+
+	groups := make([]Group, 0)
+	for _, hit := range g.index.ContainsFold(query) {
+		groups = append(groups, Group{Name: hit.Text, Count: len(g.items[hit.Text]), Score: containScore(folded, g.index.Fold(hit.Text))})
+	}
+
+	if len(groups) == 0 {
+		g.index.Scan(func(hit search.Hit, text string) bool {
+			if score := search.Similarity(folded, text); score >= threshold {
+				groups = append(groups, Group{Name: hit.Text, Count: len(g.items[hit.Text]), Score: score})
+			}
+			return true
+		})
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Score > groups[j].Score })
+	return groups
+}
+
+// find 某个名称下的歌曲/谱面, 名称不完全一致时按归一化后一致查找
+// 返回实际命中的名称
+func (g *groupIndex[T]) find(name string) (string, []T, bool) {
+	if items, ok := g.items[name]; ok {
+		return name, items, true
+	}
+
+	if hits := g.index.ExactFold(name); len(hits) != 0 {
+		return hits[0].Text, g.items[hits[0].Text], true
+	}
+
+	return name, nil, false
+}
@@ -0,0 +1,27 @@
+package finder
+
+import "testing"
+
+func TestGroupIndex(t *testing.T) {
+	g := newGroupIndex("artist", map[string][]int{"b": {3, 1}, "A": {2}}, defaultNormalize)
+
+	if groups := g.list(); len(groups) != 2 || groups[0].Name != "A" || groups[1].Count != 2 {
+		t.Errorf("unexpected list %+v", groups)
+	}
+
+	// 每个名称下的顺序保持不变
+	name, items, ok := g.find("B")
+	if !ok || name != "b" || len(items) != 2 || items[0] != 3 {
+		t.Errorf("unexpected find %s %v", name, items)
+	}
+	if _, _, ok := g.find("c"); ok {
+		t.Error("expected unknown name")
+	}
+
+	if groups := g.search("a", 0.99); len(groups) != 1 || groups[0].Name != "A" || groups[0].Score != 1 {
+		t.Errorf("unexpected search %+v", groups)
+	}
+	if groups := g.search("x", 0.99); len(groups) != 0 {
+		t.Errorf("expected no match, got %+v", groups)
+	}
+}
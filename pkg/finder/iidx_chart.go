@@ -0,0 +1,162 @@
+package finder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IIDXChart 谱面(一首歌的一个难度)
+type IIDXChart struct {
+	MID     uint   `json:"mid"`
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+	Genre   string `json:"genre"`
+	Version uint   `json:"version"`
+
+	VersionName string `json:"version_name"` // 版本名
+
+	Style      string `json:"style"`      // SP/DP
+	Difficulty string `json:"difficulty"` // beginner/normal/hyper/another/legendaria
+	Level      uint   `json:"level"`      // 等级
+}
+
+// iidxDifficulties 难度顺序
+var iidxDifficulties = []string{"beginner", "normal", "hyper", "another", "legendaria"}
+
+// iidxDifficultyAlias 难度的简写
+var iidxDifficultyAlias = map[string]string{
+	"b": "beginner", "beg": "beginner",
+	"n": "normal", "nor": "normal",
+	"h": "hyper", "hyp": "hyper",
+	"a": "another", "ano": "another",
+	"l": "legendaria", "leg": "legendaria", "leggendaria": "legendaria",
+}
+
+// levels 各难度等级, 顺序同iidxDifficulties
+func (d MusicDifficult) levels() []uint {
+	return []uint{d.Beginner, d.Normal, d.Hyper, d.Another, d.Legendaria}
+}
+
+// IIDXChartFilter 谱面筛选条件, 零值表示不限
+type IIDXChartFilter struct {
+	Style      string // SP/DP
+	Difficulty string // beginner/normal/hyper/another/legendaria
+	MinLevel   uint
+	MaxLevel   uint
+	Version    *uint // 版本号, nil为不限(0是substream)
+}
+
+// normalize 统一大小写和简写, 并校验取值
+func (filter *IIDXChartFilter) normalize() error {
+	filter.Style = strings.ToUpper(strings.TrimSpace(filter.Style))
+	if filter.Style != "" && filter.Style != "SP" && filter.Style != "DP" {
+		return fmt.Errorf("unknown style: %s", filter.Style)
+	}
+
+	filter.Difficulty = strings.ToLower(strings.TrimSpace(filter.Difficulty))
+	if full, ok := iidxDifficultyAlias[filter.Difficulty]; ok {
+		filter.Difficulty = full
+	}
+	if filter.Difficulty != "" {
+		known := false
+		for _, difficulty := range iidxDifficulties {
+			known = known || difficulty == filter.Difficulty
+		}
+		if !known {
+			return fmt.Errorf("unknown difficulty: %s", filter.Difficulty)
+		}
+	}
+
+	if filter.MaxLevel != 0 && filter.MinLevel > filter.MaxLevel {
+		return fmt.Errorf("min level %d > max level %d", filter.MinLevel, filter.MaxLevel)
+	}
+
+	return nil
+}
+
+// match 谱面是否满足条件
+func (filter *IIDXChartFilter) match(chart *IIDXChart) bool {
+	switch {
+	case filter.Style != "" && chart.Style != filter.Style:
+		return false
+	case filter.Difficulty != "" && chart.Difficulty != filter.Difficulty:
+		return false
+	case chart.Level < filter.MinLevel:
+		return false
+	case filter.MaxLevel != 0 && chart.Level > filter.MaxLevel:
+		return false
+	case filter.Version != nil && chart.Version != *filter.Version:
+		return false
+	}
+	return true
+}
+
+// buildCharts 展开全部谱面, 按等级、MID、SP/DP、难度排列
+// 等级为0的难度视为不存在
+func (c *iidxCatalog) buildCharts() {
+	charts := make([]IIDXChart, 0, len(c.music)*4)
+	for mid, music := range c.music {
+		for style, difficult := range music.Difficult {
+			for i, level := range difficult.levels() {
+				if level == 0 {
+					continue
+				}
+				charts = append(charts, IIDXChart{
+					MID:         mid,
+					Title:       music.Title,
+					Artist:      music.Artist,
+					Genre:       music.Genre,
+					Version:     music.Version,
+					VersionName: music.VersionName,
+					Style:       strings.ToUpper(style),
+					Difficulty:  iidxDifficulties[i],
+					Level:       level,
+				})
+			}
+		}
+	}
+
+	order := make(map[string]int, len(iidxDifficulties))
+	for i, difficulty := range iidxDifficulties {
+		order[difficulty] = i
+	}
+	sort.Slice(charts, func(i, j int) bool {
+		a, b := charts[i], charts[j]
+		switch {
+		case a.Level != b.Level:
+			return a.Level < b.Level
+		case a.MID != b.MID:
+			return a.MID < b.MID
+		case a.Style != b.Style:
+			return a.Style > b.Style // SP在前
+		default:
+			return order[a.Difficulty] < order[b.Difficulty]
+		}
+	})
+
+	c.charts = charts
+}
+
+// searchCharts 筛选谱面并分页
+// page从1开始, 返回当页谱面和满足条件的总数
+func (c *iidxCatalog) searchCharts(filter IIDXChartFilter, page, size int) ([]IIDXChart, int, error) {
+	if err := filter.normalize(); err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]IIDXChart, 0, size)
+	total := 0
+	start := (page - 1) * size
+	for i := range c.charts {
+		if !filter.match(&c.charts[i]) {
+			continue
+		}
+		if total >= start && len(result) < size {
+			result = append(result, c.charts[i])
+		}
+		total++
+	}
+
+	return result, total, nil
+}
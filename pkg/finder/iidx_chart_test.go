@@ -0,0 +1,84 @@
+package finder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIIDXSearchCharts(t *testing.T) {
+	c := newTestIIDXCatalog(t)
+
+	charts, total, err := c.searchCharts(IIDXChartFilter{Style: "sp", MinLevel: 12, MaxLevel: 12}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(charts) != 2 || charts[0].MID != 11032 || charts[1].MID != 19063 || charts[1].Difficulty != "another" {
+		t.Fatalf("unexpected first page %d %+v", total, charts)
+	}
+
+	charts, _, _ = c.searchCharts(IIDXChartFilter{Style: "SP", MinLevel: 12, MaxLevel: 12}, 3, 2)
+	if len(charts) != 1 || charts[0].MID != 30053 {
+		t.Errorf("unexpected last page %+v", charts)
+	}
+
+	charts, total, _ = c.searchCharts(IIDXChartFilter{Style: "DP", Difficulty: "leg"}, 1, 50)
+	if total != 1 || charts[0].MID != 19063 || charts[0].Title != "卑弥呼" || charts[0].Level != 12 {
+		t.Errorf("unexpected dp legendaria %+v", charts)
+	}
+
+	version := uint(25)
+	if _, total, _ = c.searchCharts(IIDXChartFilter{Version: &version, MinLevel: 10}, 1, 50); total != 2 {
+		t.Errorf("expected 2 charts of version 25 >= 10, got %d", total)
+	}
+
+	// 版本0是substream, 不是不限
+	substream := uint(0)
+	if _, total, _ = c.searchCharts(IIDXChartFilter{Version: &substream}, 1, 50); total != 0 {
+		t.Errorf("expected no substream charts, got %d", total)
+	}
+
+	for _, filter := range []IIDXChartFilter{{Style: "XP"}, {Difficulty: "expert"}, {MinLevel: 12, MaxLevel: 10}} {
+		if _, _, err := c.searchCharts(filter, 1, 50); err == nil {
+			t.Errorf("expected error for %+v", filter)
+		}
+	}
+}
+
+func TestGetIIDXCharts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	f := &Finder{}
+	f.iidx.Store(newTestIIDXCatalog(t))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/iidx/charts?"+query, nil)
+		f.getIIDXCharts(c)
+		return w
+	}
+
+	// 版本名和版本号都可以
+	for _, query := range []string{"version=RESIDENT&style=SP", "version=30&style=SP"} {
+		w := get(query)
+		var body struct {
+			Total  int         `json:"total"`
+			Charts []IIDXChart `json:"charts"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+		if body.Total != 3 || body.Charts[0].MID != 30053 || body.Charts[0].VersionName != "RESIDENT" {
+			t.Errorf("%s: unexpected charts %+v", query, body)
+		}
+	}
+
+	for _, query := range []string{"min=abc", "max=-1", "level=1x", "version=nope"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package finder
+
+import "sort"
+
+// iidxGroupIndex 曲师或曲风的名称索引
+type iidxGroupIndex struct {
+	*groupIndex[MusicDataInfo]
+}
+
+// newIIDXGroupIndex 按名称排序建立索引, 每个名称下的歌曲按MID排列
+func newIIDXGroupIndex(field string, musics map[string][]MusicDataInfo, fold func(string) string) *iidxGroupIndex {
+	for _, list := range musics {
+		sort.Slice(list, func(i, j int) bool { return list[i].MID < list[j].MID })
+	}
+	return &iidxGroupIndex{newGroupIndex(field, musics, fold)}
+}
+
+// iidxThreshold 曲师/曲风搜索的相似度阈值
+func (f *Finder) iidxThreshold() float64 {
+	if f.iidxSimilarityThreshold > 0 {
+		return f.iidxSimilarityThreshold
+	}
+	return defaultSimilarityThreshold
+}
+
+// matches 包含查询的名称下的全部歌曲, 供/get的模糊曲师/曲风层级使用
+func (g *iidxGroupIndex) matches(c *iidxCatalog, query string) []IIDXMatch {
+	folded := c.fold(query)
+
+	matches := make([]IIDXMatch, 0)
+	for _, hit := range g.index.ContainsFold(query) {
+		score := containScore(folded, c.fold(hit.Text))
+		for _, music := range g.items[hit.Text] {
+			matches = append(matches, IIDXMatch{MID: music.MID, Field: g.field, Text: hit.Text, Score: score})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,46 @@
+package finder
+
+import "testing"
+
+func TestIIDXArtistGenre(t *testing.T) {
+	c := newTestIIDXCatalog(t)
+
+	if artists := c.artists.list(); len(artists) != 4 {
+		t.Errorf("expected 4 artists, got %+v", artists)
+	}
+
+	if groups := c.artists.search("ryu", defaultSimilarityThreshold); len(groups) != 1 || groups[0].Name != "Ryu☆" || groups[0].Count != 2 {
+		t.Errorf("unexpected artist search %+v", groups)
+	}
+	if groups := c.artists.search("Sota Fujimory", defaultSimilarityThreshold); len(groups) != 1 || groups[0].Name != "Sota Fujimori" || groups[0].Score >= 1 {
+		t.Errorf("expected similar artist, got %+v", groups)
+	}
+	if groups := c.artists.search("Sota Fujimory", 0.99); len(groups) != 0 {
+		t.Errorf("similarity below threshold should not match, got %+v", groups)
+	}
+	if threshold := (&Finder{}).iidxThreshold(); threshold != defaultSimilarityThreshold {
+		t.Errorf("expected default threshold, got %v", threshold)
+	}
+	if threshold := (&Finder{iidxSimilarityThreshold: 0.8}).iidxThreshold(); threshold != 0.8 {
+		t.Errorf("expected configured threshold, got %v", threshold)
+	}
+	if groups := c.genres.search("hardcore", defaultSimilarityThreshold); len(groups) != 2 || groups[0].Name != "HARDCORE" {
+		t.Errorf("expected exact genre first, got %+v", groups)
+	}
+
+	name, songs, ok := c.artists.find("sota fujimori")
+	if !ok || name != "Sota Fujimori" || len(songs) != 2 || songs[0].MID != 19063 || songs[1].MID != 30053 {
+		t.Errorf("unexpected artist songs %s %+v", name, songs)
+	}
+	if _, _, ok := c.genres.find("JAZZ"); ok {
+		t.Error("expected unknown genre")
+	}
+
+	// 曲师完全一致和包含都能在/get中命中全部歌曲
+	if matches := c.match("Ryu☆", 5); len(matches) != 2 || matches[0].Tier != tierArtist {
+		t.Errorf("expected both Ryu☆ songs, got %+v", matches)
+	}
+	if matches := c.match("fujimori", 5); len(matches) != 2 || matches[0].Tier != tierArtistContains || matches[0].Text != "Sota Fujimori" {
+		t.Errorf("expected fuzzy artist match, got %+v", matches)
+	}
+}
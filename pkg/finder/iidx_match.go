@@ -0,0 +1,165 @@
+package finder
+
+import (
+	"sort"
+	"strconv"
+
+	"finder/pkg/search"
+)
+
+// IIDXMatch /get的匹配结果
+type IIDXMatch struct {
+	MID         uint    `json:"mid"`
+	Title       string  `json:"title"`
+	Version     uint    `json:"version"`
+	VersionName string  `json:"version_name"`
+	Field       string  `json:"matched_field"` // 命中的字段(nick/mid/title/artist/genre/ascii_title/title_romaji...)
+	Text        string  `json:"matched_text"`  // 命中的文本
+	Score       float64 `json:"score"`         // 层级内的匹配分数 0~1, 1为完全一致
+	Tier        int     `json:"tier"`          // 排名层级, 越小越靠前
+
+	Music *MusicDataInfo `json:"music,omitempty"` // 完整歌曲信息(detail=1时返回)
+}
+
+// iidxTier 排名层级
+// exclusive 的层级有命中时不再匹配后面的层级(精确匹配), 该层级的结果全部返回
+type iidxTier struct {
+	tier      int
+	exclusive bool
+	match     func(c *iidxCatalog, query string) []IIDXMatch
+}
+
+// IIDX /get 的排名层级, 按顺序依次匹配
+const (
+	tierNickExact         = iota + 1 // 外号完全一致
+	tierMID                          // MID
+	tierTitleExact                   // 歌名完全一致
+	tierNickFold                     // 外号归一化后一致
+	tierTitleFold                    // 歌名归一化后一致
+	tierNickContains                 // 外号包含(区分大小写)
+	tierNickContainsFold             // 外号包含(归一化)
+	tierNickPinyin                   // 外号拼音/首字母
+	tierArtist                       // 曲师完全一致
+	tierGenre                        // 曲风完全一致
+	tierArtistContains               // 曲师包含(归一化)
+	tierGenreContains                // 曲风包含(归一化)
+	tierTitleContains                // 歌名包含(区分大小写)
+	tierTitleContainsFold            // 歌名包含(归一化)
+	tierRomaji                       // AsciiTitle/曲名罗马音
+)
+
+var iidxTiers = []iidxTier{
+	{tierNickExact, true, func(c *iidxCatalog, query string) []IIDXMatch {
+		if mid, ok := c.nick[query]; ok {
+			return []IIDXMatch{{MID: mid, Field: fieldNick, Text: query, Score: 1}}
+		}
+		return nil
+	}},
+	{tierMID, true, func(c *iidxCatalog, query string) []IIDXMatch {
+		if mid, _ := strconv.Atoi(query); mid > 0 {
+			return []IIDXMatch{{MID: uint(mid), Field: fieldMID, Text: query, Score: 1}}
+		}
+		return nil
+	}},
+	{tierTitleExact, true, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.songs.Exact(query))
+	}},
+	{tierNickFold, true, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.nicks.ExactFold(query))
+	}},
+	{tierTitleFold, true, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.songs.ExactFold(query))
+	}},
+	{tierNickContains, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.nicks.Contains(query))
+	}},
+	{tierNickContainsFold, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.nicks.ContainsFold(query))
+	}},
+	{tierNickPinyin, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.nickPinyin.MatchPinyin(query))
+	}},
+	{tierArtist, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return musicMatches(c.artist[query], fieldArtist, query)
+	}},
+	{tierGenre, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return musicMatches(c.genre[query], fieldGenre, query)
+	}},
+	{tierArtistContains, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.artists.matches(c, query)
+	}},
+	{tierGenreContains, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.genres.matches(c, query)
+	}},
+	{tierTitleContains, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.songs.Contains(query))
+	}},
+	{tierTitleContainsFold, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(query, c.songs.ContainsFold(query))
+	}},
+	{tierRomaji, false, func(c *iidxCatalog, query string) []IIDXMatch {
+		return c.hits(search.Romaji(query), c.romaji.MatchRomaji(query))
+	}},
+}
+
+// hits 索引命中转换为匹配结果, 分数为查询占命中文本(归一化后)的比例
+func (c *iidxCatalog) hits(query string, hits []search.Hit) []IIDXMatch {
+	folded := c.fold(query)
+
+	matches := make([]IIDXMatch, 0, len(hits))
+	for _, hit := range hits {
+		matches = append(matches, IIDXMatch{MID: uint(hit.Doc), Field: hit.Field, Text: hit.Text, Score: containScore(folded, c.fold(hit.Key))})
+	}
+	return matches
+}
+
+// musicMatches 曲师/曲风完全一致的歌曲
+func musicMatches(musics []MusicDataInfo, field, query string) []IIDXMatch {
+	matches := make([]IIDXMatch, 0, len(musics))
+	for _, music := range musics {
+		matches = append(matches, IIDXMatch{MID: music.MID, Field: field, Text: query, Score: 1})
+	}
+	return matches
+}
+
+// match 按层级依次匹配, 同一MID只保留排名最靠前的一次
+// 结果按层级排列, 同一层级内按分数从高到低(同分按MID), 最多maxCount个
+// 同名的歌曲在精确层级中全部返回
+func (c *iidxCatalog) match(query string, maxCount int) []IIDXMatch {
+	result := make([]IIDXMatch, 0)
+	seen := make(map[uint]bool)
+
+	for _, tier := range iidxTiers {
+		matches := tier.match(c, query)
+		sort.SliceStable(matches, func(i, j int) bool {
+			if matches[i].Score != matches[j].Score {
+				return matches[i].Score > matches[j].Score
+			}
+			return matches[i].MID < matches[j].MID
+		})
+
+		for _, match := range matches {
+			if maxCount <= len(result) {
+				return result
+			}
+
+			title, ok := c.mid[match.MID]
+			if !ok || seen[match.MID] {
+				continue
+			}
+			seen[match.MID] = true
+
+			match.Title = title
+			match.Version = c.music[match.MID].Version
+			match.VersionName = c.music[match.MID].VersionName
+			match.Tier = tier.tier
+			result = append(result, match)
+		}
+
+		if tier.exclusive && len(result) != 0 {
+			return result
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,122 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestIIDXCatalog 加载testdata中的歌库并设置几个外号
+func newTestIIDXCatalog(t testing.TB) *iidxCatalog {
+	t.Helper()
+
+	f := &Finder{}
+	c := newIIDXCatalog(f.normalizer())
+	if err := f.loadMusicDB(c, filepath.Join("testdata", "music_data.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	for nick, mid := range map[string]uint{
+		"罪过的圣堂": 30053,
+		"罪过老师":  30053,
+		"卑弥呼老师": 19063,
+	} {
+		c.nick[nick] = mid
+	}
+
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	c.build()
+
+	return c
+}
+
+func TestIIDXMatchTiers(t *testing.T) {
+	c := newTestIIDXCatalog(t)
+
+	cases := []struct {
+		query string
+		want  []IIDXMatch
+	}{
+		{"罪过的圣堂", []IIDXMatch{{MID: 30053, Field: fieldNick, Tier: tierNickExact}}},
+		{"30053", []IIDXMatch{{MID: 30053, Field: fieldMID, Tier: tierMID}}},
+		{"冥", []IIDXMatch{{MID: 11032, Field: fieldTitle, Tier: tierTitleExact}}},
+		{"老师", []IIDXMatch{
+			{MID: 30053, Field: fieldNick, Tier: tierNickContains},
+			{MID: 19063, Field: fieldNick, Tier: tierNickContains},
+		}},
+		{"EPIC", []IIDXMatch{{MID: 30053, Field: fieldGenre, Tier: tierGenre}}},
+		{"bunny", []IIDXMatch{{MID: 26012, Field: fieldTitle, Tier: tierTitleContainsFold}}},
+		{"zaika", []IIDXMatch{{MID: 30053, Field: fieldAsciiTitle, Tier: tierRomaji}}},
+	}
+
+	for _, tc := range cases {
+		got := c.match(tc.query, 5)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %d matches, got %+v", tc.query, len(tc.want), got)
+			continue
+		}
+		for i, want := range tc.want {
+			if got[i].MID != want.MID || got[i].Field != want.Field || got[i].Tier != want.Tier {
+				t.Errorf("%s[%d]: expected %+v, got %+v", tc.query, i, want, got[i])
+			}
+			if got[i].Title != c.mid[want.MID] || got[i].Score <= 0 || got[i].Score > 1 {
+				t.Errorf("%s[%d]: unexpected title/score %+v", tc.query, i, got[i])
+			}
+		}
+	}
+
+	// 同一层级内按分数排列, maxCount截断
+	if got := c.match("老师", 1); len(got) != 1 || got[0].MID != 30053 {
+		t.Errorf("expected best nick only, got %+v", got)
+	}
+}
+
+func TestIIDXSong(t *testing.T) {
+	c := newTestIIDXCatalog(t)
+
+	music, err := c.song(19063)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if music.Title != "卑弥呼" || music.MID != 19063 || music.Version != 19 || music.Difficult["SP"].Legendaria != 12 {
+		t.Errorf("unexpected song %+v", music)
+	}
+
+	if _, err := c.song(1); err == nil {
+		t.Error("expected not found")
+	}
+}
+
+func TestIIDXMatchSameTitle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "music_data.json")
+	data := `{"data": [{
+		"1": {"title": "Same", "entryId": 1},
+		"2": {"title": "Same", "entryId": 2},
+		"3": {"title": "ｓａｍｅ", "entryId": 3}
+	}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Finder{}
+	c := newIIDXCatalog(f.normalizer())
+	if err := f.loadMusicDB(c, path); err != nil {
+		t.Fatal(err)
+	}
+	c.build()
+
+	// 同名的歌曲全部返回, 后面的层级不再匹配
+	if matches := c.match("Same", 5); len(matches) != 2 || matches[0].MID != 1 || matches[1].MID != 2 || matches[1].Tier != tierTitleExact {
+		t.Errorf("expected both songs titled Same, got %+v", matches)
+	}
+	if matches := c.match("SAME", 5); len(matches) != 3 || matches[2].MID != 3 || matches[2].Tier != tierTitleFold {
+		t.Errorf("expected all folded titles, got %+v", matches)
+	}
+
+	// /songs?verbose=1 不会漏掉同名的歌曲
+	if songs := c.songNames(); len(songs) != 3 || songs[1].MID != 2 {
+		t.Errorf("expected every song, got %+v", songs)
+	}
+}
@@ -0,0 +1,153 @@
+package finder
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// IIDXVersionName 内置的IIDX版本名, 下标为版本号
+var IIDXVersionName = []string{
+	"substream", "1st style", "2nd style", "3rd style", "4th style", "5th style",
+	"6th style", "7th style", "8th style", "9th style", "10th style",
+	"IIDX RED", "HAPPY SKY", "DistorteD", "GOLD", "DJ TROOPERS",
+	"EMPRESS", "SIRIUS", "Resort Anthem", "Lincle", "tricoro",
+	"SPADA", "PENDUAL", "copula", "SINOBUZ", "CANNON BALLERS",
+	"Rootage", "HEROIC VERSE", "BISTROVER", "CastHour", "RESIDENT",
+	"EPOLIS", "Pinky Crush", "Sparkle Shower",
+}
+
+// IIDXVersion 版本信息
+type IIDXVersion struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Count   int    `json:"count"` // 歌曲数
+}
+
+// IIDXSongName /songs和/nicks带版本名的列表项(verbose=1时返回)
+type IIDXSongName struct {
+	Nick        string `json:"nick,omitempty"` // 只有/nicks有
+	MID         uint   `json:"mid"`
+	Title       string `json:"title"`
+	Version     uint   `json:"version"`
+	VersionName string `json:"version_name"`
+}
+
+// songName MID对应的歌名和版本名, MID不存在时只有MID
+func (c *iidxCatalog) songName(mid uint) IIDXSongName {
+	music := c.music[mid]
+	return IIDXSongName{MID: mid, Title: music.Title, Version: music.Version, VersionName: music.VersionName}
+}
+
+// songNames 全部歌名, 按MID排列
+func (c *iidxCatalog) songNames() []IIDXSongName {
+	names := make([]IIDXSongName, 0, len(c.music))
+	for mid := range c.music {
+		names = append(names, c.songName(mid))
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].MID < names[j].MID })
+	return names
+}
+
+// nickNames 全部外号, 按外号排列
+func (c *iidxCatalog) nickNames() []IIDXSongName {
+	names := make([]IIDXSongName, 0, len(c.nick))
+	for nick, mid := range c.nick {
+		name := c.songName(mid)
+		name.Nick = nick
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Nick < names[j].Nick })
+	return names
+}
+
+// iidxVersionName 版本名, 配置中的优先, 不认识的版本返回占位名
+func (f *Finder) iidxVersionName(version uint) string {
+	if name, ok := f.iidxVersions[version]; ok {
+		return name
+	}
+	if int(version) < len(IIDXVersionName) {
+		return IIDXVersionName[version]
+	}
+	return fmt.Sprintf("unknown(%d)", version)
+}
+
+// parseIIDXVersion 版本号或版本名(忽略大小写)转版本号
+func (f *Finder) parseIIDXVersion(c *iidxCatalog, s string) (uint, bool) {
+	if version, err := strconv.Atoi(s); err == nil && version >= 0 {
+		return uint(version), true
+	}
+
+	for _, version := range f.iidxVersionList(c) {
+		if strings.EqualFold(version.Name, s) {
+			return version.Version, true
+		}
+	}
+	return 0, false
+}
+
+// iidxVersionList 全部版本(内置+配置+歌库中出现的), 按版本号排列
+func (f *Finder) iidxVersionList(c *iidxCatalog) []IIDXVersion {
+	versions := make(map[uint]bool)
+	for version := range IIDXVersionName {
+		versions[uint(version)] = true
+	}
+	for version := range f.iidxVersions {
+		versions[version] = true
+	}
+	for version := range c.version {
+		versions[version] = true
+	}
+
+	list := make([]IIDXVersion, 0, len(versions))
+	for version := range versions {
+		list = append(list, IIDXVersion{Version: version, Name: f.iidxVersionName(version), Count: len(c.version[version])})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list
+}
+
+// loadIIDXVersions 读取版本名表, 内容为 版本号->版本名
+// path为.toml时按toml解析, 否则按json解析; 相对路径基于程序目录
+func loadIIDXVersions(path string) (map[string]string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(FullPath(), path)
+	}
+
+	names := make(map[string]string)
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		if _, err := toml.DecodeFile(path, &names); err != nil {
+			return nil, err
+		}
+		return names, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// parseIIDXVersions 版本名表的key转为版本号
+func parseIIDXVersions(names map[string]string) (map[uint]string, error) {
+	versions := make(map[uint]string, len(names))
+	for key, name := range names {
+		version, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil || version < 0 {
+			return nil, fmt.Errorf("invalid iidx version: %q", key)
+		}
+		versions[uint(version)] = name
+	}
+	return versions, nil
+}
@@ -0,0 +1,70 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIIDXVersionRegistry(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "versions.json")
+	tomlPath := filepath.Join(dir, "versions.toml")
+	if err := os.WriteFile(jsonPath, []byte(`{"34": "Future"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tomlPath, []byte(`"34" = "Future"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{jsonPath, tomlPath} {
+		f := New(WithIIDXVersions(path, map[string]string{"30": "RESIDENT(CS)"}))
+
+		for version, want := range map[uint]string{31: "EPOLIS", 30: "RESIDENT(CS)", 34: "Future", 99: "unknown(99)"} {
+			if got := f.iidxVersionName(version); got != want {
+				t.Errorf("%s: version %d = %q, want %q", path, version, got, want)
+			}
+		}
+	}
+
+	if _, err := parseIIDXVersions(map[string]string{"x": "bad"}); err == nil {
+		t.Error("expected invalid version error")
+	}
+}
+
+func TestIIDXVersionList(t *testing.T) {
+	f := &Finder{}
+	c := newTestIIDXCatalog(t)
+
+	if music, _ := c.song(30053); music.VersionName != "RESIDENT" {
+		t.Errorf("expected version name on song, got %q", music.VersionName)
+	}
+	if matches := c.match("罪过的圣堂", 1); len(matches) != 1 || matches[0].VersionName != "RESIDENT" {
+		t.Errorf("expected version name on match, got %+v", matches)
+	}
+
+	counts := make(map[uint]int)
+	for _, version := range f.iidxVersionList(c) {
+		counts[version.Version] = version.Count
+	}
+	if len(counts) != len(IIDXVersionName) || counts[19] != 1 || counts[2] != 0 {
+		t.Errorf("unexpected version counts %v", counts)
+	}
+
+	for s, want := range map[string]uint{"30": 30, "resident": 30, "HEROIC VERSE": 27} {
+		if version, ok := f.parseIIDXVersion(c, s); !ok || version != want {
+			t.Errorf("parse %q = %d %v, want %d", s, version, ok, want)
+		}
+	}
+	if _, ok := f.parseIIDXVersion(c, "nope"); ok {
+		t.Error("expected unknown version")
+	}
+
+	songs := c.songNames()
+	if len(songs) != len(c.music) || songs[0].MID != 1000 || songs[len(songs)-1].VersionName != "RESIDENT" {
+		t.Errorf("unexpected song names %+v", songs)
+	}
+	if nicks := c.nickNames(); len(nicks) != 3 || nicks[0].Nick != "卑弥呼老师" || nicks[0].VersionName != "Lincle" {
+		t.Errorf("unexpected nick names %+v", nicks)
+	}
+}
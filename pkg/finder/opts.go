@@ -3,7 +3,10 @@ package finder
 import (
 	"log"
 	"sync"
+	"sync/atomic"
 
+	"finder/pkg/search"
+	"finder/pkg/storage"
 	l "finder/pkg/util/log"
 )
 
@@ -12,15 +15,19 @@ type Finder struct {
 	address string
 	port    uint
 
-	m sync.RWMutex
+	storageDriver  string        // 存储驱动 json/sqlite
+	storagePath    string        // sqlite数据库路径
+	storageBackups int           // json文件备份份数
+	store          storage.Store // 外号和别名存储
 
-	mid sync.Map //uint,string
+	normalize func(string) string // 归一化函数
 
-	name sync.Map //string,uint
-	nick sync.Map //string,uint
+	iidxVersions            map[uint]string // IIDX版本号->版本名(覆盖内置表)
+	iidxSimilarityThreshold float64         // IIDX曲师/曲风搜索的相似度阈值
+
+	m    sync.Mutex                  // 串行化重载和外号修改
+	iidx atomic.Pointer[iidxCatalog] // 当前歌库快照
 
-	genre  map[string][]MusicDataInfo //string,[]MInfo
-	artist map[string][]MusicDataInfo //string,[]MInfo
 	SDVXManager
 }
 
@@ -48,6 +55,110 @@ func WithServer(address string, port uint) Options {
 	}
 }
 
+// WithStorage 自定义外号和别名的存储
+// driver  - json(默认)/sqlite
+// path    - sqlite数据库路径
+// backups - json文件保留的备份份数(0使用默认值, 负数不备份)
+func WithStorage(driver, path string, backups int) Options {
+	return func(f *Finder) {
+		f.storageDriver = driver
+		f.storagePath = path
+		f.storageBackups = backups
+	}
+}
+
+// WithIIDXSimilarity 自定义IIDX曲师/曲风搜索的相似度匹配阈值(0~1, 0使用默认值)
+func WithIIDXSimilarity(threshold float64) Options {
+	return func(f *Finder) {
+		f.iidxSimilarityThreshold = threshold
+	}
+}
+
+// WithSDVXSimilarity 自定义SDVX相似度匹配阈值(0~1, 0使用默认值)
+func WithSDVXSimilarity(threshold float64) Options {
+	return func(f *Finder) {
+		f.SDVXManager.similarityThreshold = threshold
+	}
+}
+
+// WithSDVXRegistry 自定义SDVX版本名、第4难度简写和难度标签, 覆盖内置表
+// versions - 版本号 -> 版本名
+// infinite - 第4难度版本(inf_ver) -> 难度简写
+// levels   - 难度简写 -> music_db中的难度标签
+func WithSDVXRegistry(versions, infinite, levels map[string]string) Options {
+	return func(f *Finder) {
+		registry, err := newSDVXRegistry(versions, infinite, levels)
+		if err != nil {
+			f.panic(err)
+		}
+		f.SDVXManager.registry = registry
+	}
+}
+
+// WithSDVXFixup 自定义SDVX替代字对照表(替代字 -> 实际字符), 覆盖内置表, 替换为空时去掉该项
+func WithSDVXFixup(overrides map[string]string) Options {
+	return func(f *Finder) {
+		f.SDVXManager.fixup = newSDVXFixup(overrides)
+	}
+}
+
+// WithSDVXMusicDB 自定义SDVX数据库文件, 按顺序叠加
+// files      - 数据库文件(基础库在前, omnimix/自制谱在后), 为空使用music_db.xml
+// precedence - 同一id的取舍规则 override(后面覆盖前面, 默认)/keep(保留先出现的)
+func WithSDVXMusicDB(files []string, precedence string) Options {
+	return func(f *Finder) {
+		var err error
+		f.SDVXManager.precedence, err = parseSDVXPrecedence(precedence)
+		if err != nil {
+			f.panic(err)
+		}
+		f.SDVXManager.dbFiles = files
+	}
+}
+
+// WithNormalize 自定义歌名/外号/别名匹配时的归一化
+func WithNormalize(opts search.NormalizeOptions) Options {
+	return func(f *Finder) {
+		f.normalize = search.NewNormalizer(opts)
+		f.SDVXManager.normalize = f.normalize
+	}
+}
+
+// WithIIDXVersions 自定义IIDX版本名
+// file     - 版本名表文件(json/toml, 内容为 版本号->版本名), 为空不读取
+// versions - 直接配置的版本名, 优先于文件
+func WithIIDXVersions(file string, versions map[string]string) Options {
+	return func(f *Finder) {
+		names := make(map[string]string)
+		if file != "" {
+			fromFile, err := loadIIDXVersions(file)
+			if err != nil {
+				f.panic(err)
+			}
+			for key, name := range fromFile {
+				names[key] = name
+			}
+		}
+		for key, name := range versions {
+			names[key] = name
+		}
+
+		var err error
+		f.iidxVersions, err = parseIIDXVersions(names)
+		if err != nil {
+			f.panic(err)
+		}
+	}
+}
+
+// normalizer 歌名和外号的归一化函数
+func (f *Finder) normalizer() func(string) string {
+	if f.normalize != nil {
+		return f.normalize
+	}
+	return defaultNormalize
+}
+
 // logf 打印日志(如果没有启用则打到控制台)
 func (f *Finder) logf(format string, v ...interface{}) {
 	if f.logger != nil {
@@ -1,9 +1,10 @@
 package finder
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -32,12 +33,66 @@ func (f *Finder) routers(r *gin.Engine) {
 	f.logln("add router GET /reload")
 	r.GET("/reload", f.getReload)
 
+	f.logln("add router GET /iidx/song")
+	r.GET("/iidx/song", f.getIIDXSong)
+
+	f.logln("add router GET /iidx/charts")
+	r.GET("/iidx/charts", f.getIIDXCharts)
+
+	f.logln("add router GET /iidx/versions")
+	r.GET("/iidx/versions", f.getIIDXVersions)
+
+	f.logln("add router GET /iidx/version")
+	r.GET("/iidx/version", f.getIIDXVersion)
+
+	f.logln("add router GET /iidx/artists")
+	r.GET("/iidx/artists", f.getIIDXArtists)
+
+	f.logln("add router GET /iidx/artist")
+	r.GET("/iidx/artist", f.getIIDXArtist)
+
+	f.logln("add router GET /iidx/genres")
+	r.GET("/iidx/genres", f.getIIDXGenres)
+
+	f.logln("add router GET /iidx/genre")
+	r.GET("/iidx/genre", f.getIIDXGenre)
+
 	f.logln("add router Get /sdvx/get")
 	r.GET("/sdvx/get", f.getSDVXGet)
 
 	f.logln("add router Get /sdvx/reload")
 	r.GET("/sdvx/reload", f.getSDVXReload)
 
+	f.logln("add router Get /sdvx/loadreport")
+	r.GET("/sdvx/loadreport", f.getSDVXLoadReport)
+
+	f.logln("add router Get /sdvx/conflicts")
+	r.GET("/sdvx/conflicts", f.getSDVXConflicts)
+
+	f.logln("add router Get /sdvx/charts")
+	r.GET("/sdvx/charts", f.getSDVXCharts)
+
+	f.logln("add router Get /sdvx/similar")
+	r.GET("/sdvx/similar", f.getSDVXSimilar)
+
+	f.logln("add router Get /sdvx/random")
+	r.GET("/sdvx/random", f.getSDVXRandom)
+
+	f.logln("add router Get /sdvx/new")
+	r.GET("/sdvx/new", f.getSDVXNew)
+
+	f.logln("add router Get /sdvx/illustrators")
+	r.GET("/sdvx/illustrators", f.getSDVXIllustrators)
+
+	f.logln("add router Get /sdvx/illustrator")
+	r.GET("/sdvx/illustrator", f.getSDVXIllustrator)
+
+	f.logln("add router Get /sdvx/effectors")
+	r.GET("/sdvx/effectors", f.getSDVXEffectors)
+
+	f.logln("add router Get /sdvx/effector")
+	r.GET("/sdvx/effector", f.getSDVXEffector)
+
 	f.logln("add router Get /sdvx/aliases")
 	r.GET("/sdvx/aliases", f.getSDVXAliasList)
 
@@ -76,35 +131,43 @@ func (f *Finder) getSet(c *gin.Context) {
 		return
 	}
 
-	//判断K是否存在
-	if _, exists := f.nick.Load(nick); exists {
-		c.String(http.StatusBadRequest, "id was exists")
-		return
-	}
-
 	ids, _ := strconv.Atoi(id)
 
-	if _, exists := f.mid.Load(uint(ids)); !exists {
-		c.String(http.StatusBadRequest, "id was not exists")
+	err := f.updateNick(nick, func(catalog *iidxCatalog, nicks map[string]uint) error {
+		//判断K是否存在(归一化后相同的外号如繁简/全半角差异视为同一个)
+		if _, exists := nicks[nick]; exists || len(catalog.nicks.ExactFold(nick)) != 0 {
+			return errNickExists
+		}
+
+		if _, exists := catalog.mid[uint(ids)]; !exists {
+			return errMIDNotExists
+		}
+
+		nicks[nick] = uint(ids)
+		return nil
+	})
+
+	if errors.Is(err, errNickExists) || errors.Is(err, errMIDNotExists) {
+		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	f.nick.Store(nick, uint(ids))
-
 	f.logln("save nicks:", id, nick)
 
-	c.String(http.StatusOK, fmt.Sprintf("id: %s, nick: %s writed: %v", id, nick, f.saveNickName(filepath.Join(FullPath(), "music_nick.json"))))
+	c.String(http.StatusOK, fmt.Sprintf("id: %s, nick: %s writed: %v", id, nick, err))
 }
 
 // getGet 根据外号名获取外号的值
+// 默认返回 歌名->MID; verbose=1 时按排名层级返回有序列表, 带命中的字段、文本和分数
+// detail=1 时列表每项再带上完整歌曲信息
 func (f *Finder) getGet(c *gin.Context) {
-	m := make(map[string]uint)
-
 	nick, _ := c.GetQuery("nick")
 
 	max, _ := c.GetQuery("max")
 
-	nickId, _ := strconv.Atoi(nick)
+	verbose, _ := c.GetQuery("verbose")
+
+	detail, _ := c.GetQuery("detail")
 
 	maxCount, _ := strconv.Atoi(max)
 
@@ -117,270 +180,292 @@ func (f *Finder) getGet(c *gin.Context) {
 		return
 	}
 
-	if id, ok := f.nick.Load(nick); ok {
-		if name, ok := f.mid.Load(id); ok {
-			m[name.(string)] = id.(uint)
-			c.JSON(http.StatusOK, m)
-			return
-		}
-	}
+	catalog := f.catalog()
+	matches := catalog.match(nick, maxCount)
 
-	if nickId > 0 {
-		if name, ok := f.mid.Load(uint(nickId)); ok {
-			m[name.(string)] = uint(nickId)
-			c.JSON(http.StatusOK, m)
-			return
+	if detail != "" && detail != "0" {
+		for i := range matches {
+			matches[i].Music, _ = catalog.song(matches[i].MID)
 		}
+		c.JSON(http.StatusOK, matches)
+		return
 	}
 
-	if mid, ok := f.name.Load(nick); ok {
-		m[nick] = mid.(uint)
-		c.JSON(http.StatusOK, m)
+	if verbose != "" && verbose != "0" {
+		c.JSON(http.StatusOK, matches)
 		return
 	}
 
-	f.nick.Range(func(key, value any) bool {
-		search := key.(string)
-
-		if maxCount <= len(m) {
-			return false
+	m := make(map[string]uint)
+	for _, match := range matches {
+		if _, ok := m[match.Title]; !ok {
+			m[match.Title] = match.MID
 		}
+	}
 
-		if strings.Contains(search, nick) {
-			if name, ok := f.mid.Load(value.(uint)); ok {
-				m[name.(string)] = value.(uint)
-			}
-		}
+	c.JSON(http.StatusOK, m)
+}
 
-		return true
-	})
+// getDel 删外号
+func (f *Finder) getDel(c *gin.Context) {
+	nick, _ := c.GetQuery("nick")
 
-	f.nick.Range(func(key, value any) bool {
-		search := key.(string)
+	if nick == "" {
+		c.String(http.StatusBadRequest, "nick was nil")
+		return
+	}
 
-		if maxCount <= len(m) {
-			return false
-		}
+	f.logln("delete nicks:", nick)
 
-		if strings.Contains(strings.ToLower(search), nick) {
-			if name, ok := f.mid.Load(value.(uint)); ok {
-				m[name.(string)] = value.(uint)
-			}
-		}
+	if err := f.updateNick(nick, func(_ *iidxCatalog, nicks map[string]uint) error {
+		delete(nicks, nick)
+		return nil
+	}); err != nil {
+		f.logln("delete nicks failed:", err)
+	}
+	c.String(http.StatusOK, "")
+}
 
-		return true
-	})
+// getNicks 外号列表
+// 默认返回 外号->MID; verbose=1 时返回带歌名和版本名的列表
+func (f *Finder) getNicks(c *gin.Context) {
+	if verbose := c.Query("verbose"); verbose != "" && verbose != "0" {
+		c.JSON(http.StatusOK, f.catalog().nickNames())
+		return
+	}
 
-	f.nick.Range(func(key, value any) bool {
-		search := key.(string)
+	c.JSON(http.StatusOK, f.catalog().nick)
+}
 
-		if maxCount <= len(m) {
-			return false
-		}
+// getSongs 歌单
+// 默认返回 歌名->MID; verbose=1 时返回带版本名的列表
+func (f *Finder) getSongs(c *gin.Context) {
+	if verbose := c.Query("verbose"); verbose != "" && verbose != "0" {
+		c.JSON(http.StatusOK, f.catalog().songNames())
+		return
+	}
 
-		if strings.Contains(strings.ToLower(search), strings.ToLower(nick)) {
-			if name, ok := f.mid.Load(value.(uint)); ok {
-				m[name.(string)] = value.(uint)
-			}
-		}
+	c.JSON(http.StatusOK, f.catalog().name)
+}
 
-		return true
-	})
+// getReload 重新加载歌库和外号(失败时保留旧数据)
+func (f *Finder) getReload(c *gin.Context) {
+	if err := f.reload(); err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	if musics, ok := f.artist[nick]; ok {
-		for _, music := range musics {
-			if maxCount <= len(m) {
-				break
-			}
-			m[music.Title] = music.MID
-		}
+	c.JSON(http.StatusOK, nil)
+}
+
+// getIIDXSong 通过MID获取完整歌曲信息(不存在返回null)
+func (f *Finder) getIIDXSong(c *gin.Context) {
+	id, _ := c.GetQuery("id")
+
+	if id == "" {
+		c.String(http.StatusBadRequest, "id was nil")
+		return
 	}
 
-	if musics, ok := f.genre[nick]; ok {
-		for _, music := range musics {
-			if maxCount <= len(m) {
-				break
-			}
-			m[music.Title] = music.MID
-		}
+	mid, err := strconv.Atoi(id)
+	if err != nil || mid <= 0 {
+		c.String(http.StatusBadRequest, "id was not a number")
+		return
 	}
 
-	/*
-		for art, musics := range f.artist {
-			if maxCount <= len(m) {
-				break
-			}
+	music, _ := f.catalog().song(uint(mid))
 
-			if strings.Contains(art, nick) {
-				for _, music := range musics {
-					if maxCount <= len(m) {
-						break
-					}
-					m[music.Title] = music.MID
-				}
-			}
-		}
+	c.JSON(http.StatusOK, music)
+}
 
-		for art, musics := range f.artist {
-			if maxCount <= len(m) {
-				break
-			}
+// getIIDXCharts 按SP/DP、难度、等级范围、版本筛选谱面(分页)
+func (f *Finder) getIIDXCharts(c *gin.Context) {
+	filter := IIDXChartFilter{
+		Style:      c.Query("style"),
+		Difficulty: c.Query("diff"),
+	}
 
-			if strings.Contains(strings.ToLower(art), nick) {
-				for _, music := range musics {
-					if maxCount <= len(m) {
-						break
-					}
-					m[music.Title] = music.MID
-				}
-			}
+	for _, key := range []string{"min", "max", "level"} {
+		value := c.Query(key)
+		if value == "" {
+			continue
 		}
-
-		for art, musics := range f.artist {
-			if maxCount <= len(m) {
-				break
-			}
-
-			if strings.Contains(strings.ToLower(art), strings.ToLower(nick)) {
-				for _, music := range musics {
-					if maxCount <= len(m) {
-						break
-					}
-					m[music.Title] = music.MID
-				}
-			}
+		level, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid "+key+": "+value)
+			return
 		}
-
-		for gen, musics := range f.genre {
-			if maxCount <= len(m) {
-				break
-			}
-
-			if strings.Contains(gen, nick) {
-				for _, music := range musics {
-					if maxCount <= len(m) {
-						break
-					}
-					m[music.Title] = music.MID
-				}
+		switch key {
+		case "min":
+			filter.MinLevel = uint(level)
+		case "max":
+			filter.MaxLevel = uint(level)
+		default:
+			if level > 0 {
+				filter.MinLevel, filter.MaxLevel = uint(level), uint(level)
 			}
 		}
+	}
 
-		for gen, musics := range f.genre {
-			if maxCount <= len(m) {
-				break
-			}
+	catalog := f.catalog()
 
-			if strings.Contains(strings.ToLower(gen), nick) {
-				for _, music := range musics {
-					if maxCount <= len(m) {
-						break
-					}
-					m[music.Title] = music.MID
-				}
-			}
+	if v := c.Query("version"); v != "" {
+		version, ok := f.parseIIDXVersion(catalog, v)
+		if !ok {
+			c.String(http.StatusBadRequest, "unknown version: "+v)
+			return
 		}
+		filter.Version = &version
+	}
 
-		for gen, musics := range f.genre {
-			if maxCount <= len(m) {
-				break
-			}
+	page, size := pagination(c)
 
-			if strings.Contains(strings.ToLower(gen), strings.ToLower(nick)) {
-				for _, music := range musics {
-					if maxCount <= len(m) {
-						break
-					}
-					m[music.Title] = music.MID
-				}
-			}
-		}
-	*/
+	charts, total, err := catalog.searchCharts(filter, page, size)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
 
-	f.name.Range(func(key, value any) bool {
-		search := key.(string)
+	c.JSON(http.StatusOK, map[string]any{
+		"total":  total,
+		"page":   page,
+		"size":   size,
+		"charts": charts,
+	})
+}
 
-		if maxCount <= len(m) {
-			return false
-		}
+// getIIDXVersions 版本列表(带歌曲数)
+func (f *Finder) getIIDXVersions(c *gin.Context) {
+	c.JSON(http.StatusOK, f.iidxVersionList(f.catalog()))
+}
 
-		if strings.Contains(search, nick) {
-			m[key.(string)] = value.(uint)
-		}
+// getIIDXVersion 某个版本的歌曲(按MID排列, 分页), v可以是版本号或版本名
+func (f *Finder) getIIDXVersion(c *gin.Context) {
+	v, _ := c.GetQuery("v")
 
-		return true
-	})
+	if v == "" {
+		c.String(http.StatusBadRequest, "v was nil")
+		return
+	}
 
-	f.name.Range(func(key, value any) bool {
-		search := key.(string)
+	catalog := f.catalog()
 
-		if maxCount <= len(m) {
-			return false
-		}
+	version, ok := f.parseIIDXVersion(catalog, v)
+	if !ok {
+		c.String(http.StatusBadRequest, "unknown version: "+v)
+		return
+	}
 
-		if strings.Contains(strings.ToLower(search), nick) {
-			m[key.(string)] = value.(uint)
-		}
+	songs := make([]MusicDataInfo, 0, len(catalog.version[version]))
+	songs = append(songs, catalog.version[version]...)
+	sort.Slice(songs, func(i, j int) bool { return songs[i].MID < songs[j].MID })
+
+	page, size := pagination(c)
+	start, end := pageRange(len(songs), page, size)
 
-		return true
+	c.JSON(http.StatusOK, map[string]any{
+		"version":      version,
+		"version_name": f.iidxVersionName(version),
+		"total":        len(songs),
+		"page":         page,
+		"size":         size,
+		"songs":        songs[start:end],
 	})
+}
 
-	f.name.Range(func(key, value any) bool {
-		search := key.(string)
+// getIIDXArtists 曲师列表(带歌曲数), q不为空时模糊搜索
+func (f *Finder) getIIDXArtists(c *gin.Context) {
+	f.listIIDXGroups(c, f.catalog().artists)
+}
 
-		if maxCount <= len(m) {
-			return false
-		}
+// getIIDXArtist 某个曲师的歌曲(分页)
+func (f *Finder) getIIDXArtist(c *gin.Context) {
+	listIIDXGroupSongs(c, f.catalog().artists)
+}
 
-		if strings.Contains(strings.ToLower(search), strings.ToLower(nick)) {
-			m[key.(string)] = value.(uint)
-		}
+// getIIDXGenres 曲风列表(带歌曲数), q不为空时模糊搜索
+func (f *Finder) getIIDXGenres(c *gin.Context) {
+	f.listIIDXGroups(c, f.catalog().genres)
+}
 
-		return true
-	})
+// getIIDXGenre 某个曲风的歌曲(分页)
+func (f *Finder) getIIDXGenre(c *gin.Context) {
+	listIIDXGroupSongs(c, f.catalog().genres)
+}
 
-	c.JSON(http.StatusOK, m)
+// listIIDXGroups 曲师/曲风列表
+func (f *Finder) listIIDXGroups(c *gin.Context, groups *iidxGroupIndex) {
+	if query, _ := c.GetQuery("q"); query != "" {
+		c.JSON(http.StatusOK, groups.search(query, f.iidxThreshold()))
+		return
+	}
+
+	c.JSON(http.StatusOK, groups.list())
 }
 
-// getDel 删外号
-func (f *Finder) getDel(c *gin.Context) {
-	nick, _ := c.GetQuery("nick")
+// listIIDXGroupSongs 曲师/曲风下的歌曲
+func listIIDXGroupSongs(c *gin.Context, groups *iidxGroupIndex) {
+	name, _ := c.GetQuery("name")
 
-	if nick == "" {
-		c.String(http.StatusBadRequest, "nick was nil")
+	if name == "" {
+		c.String(http.StatusBadRequest, "name was nil")
 		return
 	}
 
-	f.logln("delete nicks:", nick)
+	name, songs, ok := groups.find(name)
+	if !ok {
+		c.String(http.StatusNotFound, groups.field+" not found: "+name)
+		return
+	}
 
-	f.nick.Delete(nick)
-	f.saveNickName(filepath.Join(FullPath(), "music_nick.json"))
-	c.String(http.StatusOK, "")
-}
+	page, size := pagination(c)
+	start, end := pageRange(len(songs), page, size)
 
-// getNicks 外号列表
-func (f *Finder) getNicks(c *gin.Context) {
-	m := make(map[string]uint)
-	f.nick.Range(func(key, value any) bool {
-		m[key.(string)] = value.(uint)
-		return true
+	c.JSON(http.StatusOK, map[string]any{
+		"name":  name,
+		"total": len(songs),
+		"page":  page,
+		"size":  size,
+		"songs": songs[start:end],
 	})
-	c.JSON(http.StatusOK, m)
 }
 
-// getSongs 歌单
-func (f *Finder) getSongs(c *gin.Context) {
-	m := make(map[string]uint)
-	f.name.Range(func(key, value any) bool {
-		m[key.(string)] = value.(uint)
-		return true
-	})
-	c.JSON(http.StatusOK, m)
+// pagination 分页参数 page(从1开始, 默认1) size(默认50, 最大500)
+func pagination(c *gin.Context) (page, size int) {
+	page, _ = strconv.Atoi(c.Query("page"))
+	size, _ = strconv.Atoi(c.Query("size"))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 50
+	}
+	if size > 500 {
+		size = 500
+	}
+	return page, size
 }
 
-// getSongs 歌单
-func (f *Finder) getReload(c *gin.Context) {
-	c.JSON(http.StatusOK, f.reload())
+// pageRange 第page页在长度为total的列表中的范围
+func pageRange(total, page, size int) (start, end int) {
+	start = (page - 1) * size
+	if start > total {
+		start = total
+	}
+	end = start + size
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// SDVXScoredMusicInfo 带匹配分数的曲目信息
+type SDVXScoredMusicInfo struct {
+	SDVXMusicInfo
+	Score        float64 `json:"score"`         // 匹配分数 0~1
+	MatchedField string  `json:"matched_field"` // 命中的字段
+	MatchedText  string  `json:"matched_text"`  // 命中的文本
 }
 
 // getSDVXGet 搜歌
@@ -399,14 +484,14 @@ func (f *Finder) getSDVXGet(c *gin.Context) {
 			result = nil
 		}
 	} else if isQueryMatch {
-		resultList := make([]SDVXMusicInfo, 0)
-		ids := f.SDVXManager.SimpleMatch(queryMatch)
-		for _, id := range ids {
-			info, err := f.SDVXManager.Get(id)
+		resultList := make([]SDVXScoredMusicInfo, 0)
+		matches := f.SDVXManager.SimpleMatchScored(queryMatch)
+		for _, match := range matches {
+			info, err := f.SDVXManager.Get(match.Id)
 			if err != nil || info == nil {
 				continue // 跳过无效的 `info`
 			}
-			resultList = append(resultList, *info)
+			resultList = append(resultList, SDVXScoredMusicInfo{SDVXMusicInfo: *info, Score: match.Score, MatchedField: match.Field, MatchedText: match.Text})
 		}
 		result = resultList
 	} else {
@@ -419,13 +504,274 @@ func (f *Finder) getSDVXGet(c *gin.Context) {
 // getSDVXReload 加载sdvx数据库和别名
 func (f *Finder) getSDVXReload(c *gin.Context) {
 	if e := f.sdvxLoadUni(); e != nil {
-		c.JSON(http.StatusInternalServerError, "failure")
+		c.JSON(http.StatusInternalServerError, "failure: "+e.Error())
 		return
 	}
 
 	c.JSON(http.StatusOK, "ok")
 }
 
+// getSDVXLoadReport 最近一次加载sdvx数据库的报告(跳过的曲目和有问题的字段)
+func (f *Finder) getSDVXLoadReport(c *gin.Context) {
+	c.JSON(http.StatusOK, f.SDVXManager.LoadReport())
+}
+
+// getSDVXCharts 按等级范围、难度槽、版本、第4难度版本、limited、is_fixed筛选谱面(分页)
+func (f *Finder) getSDVXCharts(c *gin.Context) {
+	filter, err := sdvxChartFilter(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, size := pagination(c)
+
+	charts, total, err := f.SDVXManager.SearchCharts(filter, page, size)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]any{
+		"total":  total,
+		"page":   page,
+		"size":   size,
+		"charts": charts,
+	})
+}
+
+// getSDVXSimilar 六维最接近的谱面
+// 有id时与该曲目diff难度的六维比较(window为等级相差范围), 否则按notes/peak/tsumami/tricky/hand_trip/one_hand画像查找
+// 都可以再加/sdvx/charts的筛选条件
+func (f *Finder) getSDVXSimilar(c *gin.Context) {
+	filter, err := sdvxChartFilter(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, _ := strconv.Atoi(c.Query("count"))
+	if count < 1 {
+		count = 10
+	}
+	if count > 100 {
+		count = 100
+	}
+
+	if id, isId := c.GetQuery("id"); isId {
+		sid, err := strconv.ParseInt(id, 10, 32)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid id: "+id)
+			return
+		}
+		window, _ := strconv.Atoi(c.Query("window"))
+
+		source, charts, err := f.SDVXManager.SimilarCharts(int32(sid), c.Query("diff"), window, filter, count)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]any{
+			"source": source,
+			"charts": charts,
+		})
+		return
+	}
+
+	profile := make(map[string]string)
+	for key := range c.Request.URL.Query() {
+		if _, ok := radarField(key); ok {
+			profile[key] = c.Query(key)
+		}
+	}
+
+	resolved, charts, err := f.SDVXManager.ChartsByRadar(profile, filter, count)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]any{
+		"profile": resolved,
+		"charts":  charts,
+	})
+}
+
+// getSDVXRandom 从满足/sdvx/charts筛选条件的谱面中随机抽count个(同一首曲目只抽一次)
+// weight为chart/song/level, seed相同时结果相同, seed=daily时当天结果相同
+func (f *Finder) getSDVXRandom(c *gin.Context) {
+	filter, err := sdvxChartFilter(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, _ := strconv.Atoi(c.Query("count"))
+	if count < 1 {
+		count = 1
+	}
+	if count > 100 {
+		count = 100
+	}
+
+	charts, seed, err := f.SDVXManager.RandomCharts(filter, count, c.Query("weight"), c.Query("seed"))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]any{
+		"seed":   seed,
+		"charts": charts,
+	})
+}
+
+// getSDVXNew since之后(含)发布的新曲, 按月分组, 可以用until限制截止日期
+func (f *Finder) getSDVXNew(c *gin.Context) {
+	since, err := parseSDVXDate(c.Query("since"))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var until uint32
+	if date := c.Query("until"); date != "" {
+		if until, err = parseSDVXDate(date); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, f.SDVXManager.NewSongs(since, until))
+}
+
+// getSDVXIllustrators 曲绘画师列表(带谱面数), q不为空时模糊搜索
+func (f *Finder) getSDVXIllustrators(c *gin.Context) {
+	c.JSON(http.StatusOK, f.SDVXManager.Creators(fieldIllustrator, c.Query("q")))
+}
+
+// getSDVXIllustrator 某个曲绘画师的谱面(分页)
+func (f *Finder) getSDVXIllustrator(c *gin.Context) {
+	f.listSDVXCreatorCharts(c, fieldIllustrator)
+}
+
+// getSDVXEffectors 谱师列表(带谱面数), q不为空时模糊搜索
+func (f *Finder) getSDVXEffectors(c *gin.Context) {
+	c.JSON(http.StatusOK, f.SDVXManager.Creators(fieldEffector, c.Query("q")))
+}
+
+// getSDVXEffector 某个谱师的谱面(分页)
+func (f *Finder) getSDVXEffector(c *gin.Context) {
+	f.listSDVXCreatorCharts(c, fieldEffector)
+}
+
+// listSDVXCreatorCharts 曲绘画师/谱师的谱面
+func (f *Finder) listSDVXCreatorCharts(c *gin.Context, field string) {
+	name, _ := c.GetQuery("name")
+
+	if name == "" {
+		c.String(http.StatusBadRequest, "name was nil")
+		return
+	}
+
+	name, charts, ok := f.SDVXManager.CreatorCharts(field, name)
+	if !ok {
+		c.String(http.StatusNotFound, field+" not found: "+name)
+		return
+	}
+
+	page, size := pagination(c)
+	start, end := pageRange(len(charts), page, size)
+
+	c.JSON(http.StatusOK, map[string]any{
+		"name":   name,
+		"total":  len(charts),
+		"page":   page,
+		"size":   size,
+		"charts": charts[start:end],
+	})
+}
+
+// sdvxChartFilter 从请求参数读取谱面筛选条件
+// level/min/max slot version diffver4 limited fixed(0/1) bpmmin/bpmmax bpmchange(0/1) genre datefrom/dateto
+func sdvxChartFilter(c *gin.Context) (SDVXChartFilter, error) {
+	filter := SDVXChartFilter{
+		Slot:     c.Query("slot"),
+		Version:  c.Query("version"),
+		DiffVer4: c.Query("diffver4"),
+	}
+
+	for _, key := range []string{"min", "max", "level", "limited"} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: %s", key, value)
+		}
+		n := uint8(parsed)
+		switch key {
+		case "min":
+			filter.MinLevel = n
+		case "max":
+			filter.MaxLevel = n
+		case "level":
+			if n > 0 {
+				filter.MinLevel, filter.MaxLevel = n, n
+			}
+		default:
+			filter.Limited = &n
+		}
+	}
+
+	for key, to := range map[string]**bool{"fixed": &filter.IsFixed, "bpmchange": &filter.BPMChange} {
+		if flag := c.Query(key); flag != "" {
+			value, err := strconv.ParseBool(flag)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", key, flag)
+			}
+			*to = &value
+		}
+	}
+
+	for key, to := range map[string]*float32{"bpmmin": &filter.MinBPM, "bpmmax": &filter.MaxBPM} {
+		if bpm := c.Query(key); bpm != "" {
+			value, err := strconv.ParseFloat(bpm, 32)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", key, bpm)
+			}
+			*to = float32(value)
+		}
+	}
+
+	if genre := c.Query("genre"); genre != "" {
+		value, err := strconv.ParseUint(genre, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("invalid genre: %s", genre)
+		}
+		filter.Genre = uint32(value)
+	}
+
+	for key, to := range map[string]*uint32{"datefrom": &filter.MinDate, "dateto": &filter.MaxDate} {
+		if date := c.Query(key); date != "" {
+			value, err := parseSDVXDate(date)
+			if err != nil {
+				return filter, err
+			}
+			*to = value
+		}
+	}
+
+	return filter, nil
+}
+
+// getSDVXConflicts 最近一次加载时多个数据库文件间的id冲突
+func (f *Finder) getSDVXConflicts(c *gin.Context) {
+	c.JSON(http.StatusOK, f.SDVXManager.Conflicts())
+}
+
 // getSDVXAliasList 获取别名列表
 func (f *Finder) getSDVXAliasList(c *gin.Context) {
 	var result any
@@ -492,6 +838,13 @@ func (f *Finder) getSDVXMatchId(c *gin.Context) {
 		useFuzzy = true
 	}
 
+	// 相似度匹配(容错), 返回带分数的结果
+	if isSimilar, _ := c.GetQuery("issimilar"); isSimilar != "" && isSimilar != "0" {
+		result["contents"] = f.SDVXManager.SimilarMatch(query, isAlias != "0")
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
 	if isAlias == "0" {
 		result["contents"] = f.SDVXManager.Match(query, useNoCase, useFuzzy)
 		// 曲目名称查找id
@@ -0,0 +1,211 @@
+package finder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SDVXChart 谱面(一首曲目的一个难度)
+type SDVXChart struct {
+	Id               int32   `json:"id"`
+	Title            string  `json:"title"`
+	Artist           string  `json:"artist"`
+	Version          string  `json:"version"`           // 曲目更新版本
+	DiffVer4         string  `json:"diff_ver4"`         // 第4难度追加版本
+	IsFixed          bool    `json:"is_fixed"`          // 希腊奶
+	Genre            uint32  `json:"genre"`             // 类型
+	BPMMax           float32 `json:"bpm_max"`           // 最大bpm
+	BPMMin           float32 `json:"bpm_min"`           // 最小bpm
+	DistributionDate uint32  `json:"distribution_date"` // 曲目发布日期
+
+	Slot string `json:"slot"` // 难度简写 nov/adv/exh/inf(grv/hvn/vvd/xcd/nbl)/mxm/ult
+	DifficultyInfo
+}
+
+// sdvxFixedSlots 第4难度以外的难度槽
+var sdvxFixedSlots = map[string]int{"nov": 0, "adv": 1, "exh": 2, "mxm": 4, "ult": 5}
+
+// sdvxSlotAlias 难度槽的全称
+var sdvxSlotAlias = map[string]string{
+	"novice":   "nov",
+	"advanced": "adv",
+	"exhaust":  "exh",
+	"infinite": "inf",
+	"gravity":  "grv",
+	"heavenly": "hvn",
+	"vivid":    "vvd",
+	"exceed":   "xcd",
+	"maximum":  "mxm",
+	"ultimate": "ult",
+}
+
+// sdvxSlotOrder 难度槽的顺序, 第4难度(inf一族)都排在exh之后
+func sdvxSlotOrder(slot string) int {
+	if order, ok := sdvxFixedSlots[slot]; ok {
+		return order
+	}
+	return 3
+}
+
+// SDVXChartFilter 谱面筛选条件, 零值表示不限
+type SDVXChartFilter struct {
+	Slot      string // nov/adv/exh/mxm/ult, inf表示任意第4难度, grv/hvn等表示该版本的第4难度
+	MinLevel  uint8
+	MaxLevel  uint8
+	Version   string // 曲目版本, 版本号/版本名/版本名首字母
+	DiffVer4  string // 第4难度追加版本, 同上
+	Limited   *uint8 // limited取值, nil为不限
+	IsFixed   *bool
+	MinBPM    float32 // 曲目的bpm全部落在[MinBPM, MaxBPM]内
+	MaxBPM    float32
+	Genre     uint32 // 类型(按位), 与曲目类型有交集即可
+	BPMChange *bool  // 是否变速(最小bpm不等于最大bpm)
+	MinDate   uint32 // 发布日期范围 YYYYMMDD
+	MaxDate   uint32
+}
+
+// normalize 统一大小写、简写和版本名, 并校验取值
+func (filter *SDVXChartFilter) normalize(registry *sdvxRegistry) error {
+	filter.Slot = strings.ToLower(strings.TrimSpace(filter.Slot))
+	if abbr, ok := sdvxSlotAlias[filter.Slot]; ok {
+		filter.Slot = abbr
+	}
+	if filter.Slot != "" && filter.Slot != "inf" {
+		if _, fixed := sdvxFixedSlots[filter.Slot]; !fixed && !registry.isInfinite(filter.Slot) {
+			return fmt.Errorf("unknown slot: %s", filter.Slot)
+		}
+	}
+
+	if filter.MaxLevel != 0 && filter.MinLevel > filter.MaxLevel {
+		return fmt.Errorf("min level %d > max level %d", filter.MinLevel, filter.MaxLevel)
+	}
+	if filter.MinBPM < 0 || filter.MaxBPM < 0 || (filter.MaxBPM != 0 && filter.MinBPM > filter.MaxBPM) {
+		return fmt.Errorf("invalid bpm range %g~%g", filter.MinBPM, filter.MaxBPM)
+	}
+	if filter.MaxDate != 0 && filter.MinDate > filter.MaxDate {
+		return fmt.Errorf("min date %d > max date %d", filter.MinDate, filter.MaxDate)
+	}
+
+	for _, version := range []*string{&filter.Version, &filter.DiffVer4} {
+		if *version == "" {
+			continue
+		}
+		name, ok := registry.parseVersion(*version)
+		if !ok {
+			return fmt.Errorf("unknown version: %s", *version)
+		}
+		*version = name
+	}
+
+	return nil
+}
+
+// match 谱面是否满足条件
+func (filter *SDVXChartFilter) match(chart *SDVXChart) bool {
+	switch {
+	case filter.Slot == "inf" && sdvxSlotOrder(chart.Slot) != 3:
+		return false
+	case filter.Slot != "" && filter.Slot != "inf" && chart.Slot != filter.Slot:
+		return false
+	case chart.Level < filter.MinLevel:
+		return false
+	case filter.MaxLevel != 0 && chart.Level > filter.MaxLevel:
+		return false
+	case filter.Version != "" && chart.Version != filter.Version:
+		return false
+	case filter.DiffVer4 != "" && chart.DiffVer4 != filter.DiffVer4:
+		return false
+	case filter.Limited != nil && chart.Limited != *filter.Limited:
+		return false
+	case filter.IsFixed != nil && chart.IsFixed != *filter.IsFixed:
+		return false
+	case chart.BPMMin < filter.MinBPM:
+		return false
+	case filter.MaxBPM != 0 && chart.BPMMax > filter.MaxBPM:
+		return false
+	case filter.Genre != 0 && chart.Genre&filter.Genre == 0:
+		return false
+	case filter.BPMChange != nil && (chart.BPMMin != chart.BPMMax) != *filter.BPMChange:
+		return false
+	case chart.DistributionDate < filter.MinDate:
+		return false
+	case filter.MaxDate != 0 && chart.DistributionDate > filter.MaxDate:
+		return false
+	}
+	return true
+}
+
+// buildCharts 展开全部谱面, 按等级、id、难度槽排列
+// 等级为0的难度视为不存在
+func (c *sdvxCatalog) buildCharts() {
+	charts := make([]SDVXChart, 0, len(c.musics)*4)
+	for id, music := range c.musics {
+		for _, slot := range music.DifficultyList {
+			difficulty := music.Difficulties[slot]
+			if difficulty.Level == 0 {
+				continue
+			}
+			charts = append(charts, SDVXChart{
+				Id:               id,
+				Title:            music.TitleName,
+				Artist:           music.ArtistName,
+				Version:          music.Version,
+				DiffVer4:         music.DiffVer4,
+				IsFixed:          music.IsFixed,
+				Genre:            music.Genre,
+				BPMMax:           music.BPMMax,
+				BPMMin:           music.BPMMin,
+				DistributionDate: music.DistributionDate,
+				Slot:             slot,
+				DifficultyInfo:   difficulty,
+			})
+		}
+	}
+
+	sort.Slice(charts, func(i, j int) bool {
+		a, b := charts[i], charts[j]
+		switch {
+		case a.Level != b.Level:
+			return a.Level < b.Level
+		case a.Id != b.Id:
+			return a.Id < b.Id
+		default:
+			return sdvxSlotOrder(a.Slot) < sdvxSlotOrder(b.Slot)
+		}
+	})
+
+	c.charts = charts
+}
+
+// charts 当前曲库快照中的谱面
+func (manager *SDVXManager) charts() []SDVXChart {
+	if c := manager.catalog.Load(); c != nil {
+		return c.charts
+	}
+	return nil
+}
+
+// SearchCharts 筛选谱面并分页
+// page从1开始, 返回当页谱面和满足条件的总数
+func (manager *SDVXManager) SearchCharts(filter SDVXChartFilter, page, size int) ([]SDVXChart, int, error) {
+	if err := filter.normalize(manager.sdvxRegistry()); err != nil {
+		return nil, 0, err
+	}
+
+	charts := manager.charts()
+	result := make([]SDVXChart, 0, size)
+	total := 0
+	start := (page - 1) * size
+	for i := range charts {
+		if !filter.match(&charts[i]) {
+			continue
+		}
+		if total >= start && len(result) < size {
+			result = append(result, charts[i])
+		}
+		total++
+	}
+
+	return result, total, nil
+}
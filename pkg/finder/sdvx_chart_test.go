@@ -0,0 +1,114 @@
+package finder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSDVXSearchCharts(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	charts, total, err := manager.SearchCharts(SDVXChartFilter{MinLevel: 19, MaxLevel: 19}, 1, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || charts[0].Id != 3 || charts[0].Slot != "mxm" || charts[1].Id != 4 || charts[1].Slot != "vvd" {
+		t.Fatalf("unexpected lv19 charts %d %+v", total, charts)
+	}
+
+	// 版本名首字母
+	charts, total, _ = manager.SearchCharts(SDVXChartFilter{MinLevel: 19, MaxLevel: 19, Version: "eg"}, 1, 50)
+	if total != 1 || charts[0].Id != 5 || charts[0].Version != "Exceed Gear" {
+		t.Errorf("unexpected lv19 from EG %+v", charts)
+	}
+
+	// inf表示任意第4难度, 等级为0的难度不算
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{Slot: "inf"}, 1, 50); total != 5 {
+		t.Errorf("expected 5 fourth charts, got %d", total)
+	}
+	if charts, total, _ = manager.SearchCharts(SDVXChartFilter{Slot: "exceed"}, 1, 50); total != 1 || charts[0].Id != 6 {
+		t.Errorf("unexpected xcd charts %+v", charts)
+	}
+
+	fixed := true
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{IsFixed: &fixed}, 1, 50); total != 4 {
+		t.Errorf("expected 4 fixed charts, got %d", total)
+	}
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{DiffVer4: "5"}, 1, 50); total != 4 {
+		t.Errorf("expected 4 charts of diff ver 5, got %d", total)
+	}
+	limited := uint8(1)
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{Limited: &limited}, 1, 50); total != 0 {
+		t.Errorf("expected no limited=1 charts, got %d", total)
+	}
+	// limited=0 也是一个取值, 不是不限
+	limited = 0
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{Limited: &limited}, 1, 50); total != 0 {
+		t.Errorf("expected no limited=0 charts, got %d", total)
+	}
+	limited = 3
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{Limited: &limited}, 1, 50); total == 0 {
+		t.Error("expected limited=3 charts")
+	}
+
+	charts, total, _ = manager.SearchCharts(SDVXChartFilter{MinLevel: 18}, 2, 4)
+	if total != 6 || len(charts) != 2 || charts[0].Level != 19 {
+		t.Errorf("unexpected last page %d %+v", total, charts)
+	}
+
+	for _, filter := range []SDVXChartFilter{{Slot: "hard"}, {Version: "XX"}, {MinLevel: 19, MaxLevel: 17}} {
+		if _, _, err := manager.SearchCharts(filter, 1, 50); err == nil {
+			t.Errorf("expected error for %+v", filter)
+		}
+	}
+}
+
+func TestSDVXChartBPMAndDateFilter(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	change := true
+	charts, total, _ := manager.SearchCharts(SDVXChartFilter{BPMChange: &change}, 1, 50)
+	if total != 5 || charts[0].Id != 3 {
+		t.Errorf("expected 5 charts of song 3 with bpm changes, got %d %+v", total, charts)
+	}
+	change = false
+	if _, total, _ = manager.SearchCharts(SDVXChartFilter{BPMChange: &change, MinBPM: 175}, 1, 50); total != 12 {
+		t.Errorf("expected 12 constant bpm charts >= 175, got %d", total)
+	}
+
+	charts, total, _ = manager.SearchCharts(SDVXChartFilter{MinDate: 20190101, MaxDate: 20201231, Slot: "exh"}, 1, 50)
+	if total != 2 || charts[0].Id != 3 || charts[1].Id != 4 {
+		t.Errorf("unexpected charts released in 2019~2020 %+v", charts)
+	}
+
+	if _, _, err := manager.SearchCharts(SDVXChartFilter{MinDate: 20220101, MaxDate: 20210101}, 1, 50); err == nil {
+		t.Error("expected date range error")
+	}
+}
+
+func TestSDVXChartFilterQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	parse := func(query string) (SDVXChartFilter, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/sdvx/charts?"+query, nil)
+		return sdvxChartFilter(c)
+	}
+
+	filter, err := parse("min=17&max=18&limited=0")
+	if err != nil || filter.MinLevel != 17 || filter.MaxLevel != 18 || filter.Limited == nil || *filter.Limited != 0 {
+		t.Errorf("unexpected filter %+v %v", filter, err)
+	}
+	if filter, _ = parse("min=17&level=19"); filter.MinLevel != 19 || filter.MaxLevel != 19 || filter.Limited != nil {
+		t.Errorf("level should override min/max %+v", filter)
+	}
+
+	for _, query := range []string{"min=abc", "max=-1", "level=256", "limited=x"} {
+		if _, err := parse(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package finder
+
+import "strings"
+
+// SDVXCreator 曲绘画师或谱师
+type SDVXCreator = Group
+
+// sdvxCreatorIndex 曲绘画师或谱师的名称索引
+type sdvxCreatorIndex = groupIndex[SDVXChart]
+
+// newSDVXCreatorIndex 按名称分组建立索引, 每个名称下的谱面顺序同charts
+// 名称为空的谱面不计入
+func newSDVXCreatorIndex(field string, charts []SDVXChart, name func(*SDVXChart) string, fold func(string) string) *sdvxCreatorIndex {
+	byName := make(map[string][]SDVXChart)
+	for i := range charts {
+		if n := strings.TrimSpace(name(&charts[i])); n != "" {
+			byName[n] = append(byName[n], charts[i])
+		}
+	}
+	return newGroupIndex(field, byName, fold)
+}
+
+// buildCreators 建立曲绘画师和谱师索引, 在buildCharts之后调用
+func (c *sdvxCatalog) buildCreators(fold func(string) string) {
+	c.illustrators = newSDVXCreatorIndex(fieldIllustrator, c.charts, func(chart *SDVXChart) string { return chart.Illustrator }, fold)
+	c.effectors = newSDVXCreatorIndex(fieldEffector, c.charts, func(chart *SDVXChart) string { return chart.EffectedBy }, fold)
+}
+
+// creators 当前曲库快照中的曲绘画师或谱师索引
+func (manager *SDVXManager) creators(field string) *sdvxCreatorIndex {
+	c := manager.catalog.Load()
+	if c == nil {
+		return newSDVXCreatorIndex(field, nil, nil, manager.normalizer())
+	}
+	if field == fieldIllustrator {
+		return c.illustrators
+	}
+	return c.effectors
+}
+
+// Creators 全部曲绘画师或谱师(带谱面数), query不为空时模糊搜索
+func (manager *SDVXManager) Creators(field, query string) []SDVXCreator {
+	if query == "" {
+		return manager.creators(field).list()
+	}
+	return manager.creators(field).search(query, manager.threshold())
+}
+
+// CreatorCharts 某个曲绘画师或谱师的谱面, 返回实际命中的名称
+func (manager *SDVXManager) CreatorCharts(field, name string) (string, []SDVXChart, bool) {
+	return manager.creators(field).find(name)
+}
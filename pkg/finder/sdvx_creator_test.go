@@ -0,0 +1,40 @@
+package finder
+
+import "testing"
+
+func TestSDVXCreators(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	// 等级为0的难度(dummy)不计入
+	illustrators := manager.Creators(fieldIllustrator, "")
+	if len(illustrators) != 6 || illustrators[0].Name != "Jacket A" || illustrators[0].Count != 5 {
+		t.Errorf("unexpected illustrators %+v", illustrators)
+	}
+	if effectors := manager.Creators(fieldEffector, ""); len(effectors) != 5 {
+		t.Errorf("unexpected effectors %+v", effectors)
+	}
+
+	// 归一化后包含, 匹配不到时按相似度容错
+	if found := manager.Creators(fieldIllustrator, "ミフル"); len(found) != 1 || found[0].Name != "みふる" || found[0].Count != 4 {
+		t.Errorf("expected kana folded illustrator, got %+v", found)
+	}
+	if found := manager.Creators(fieldEffector, "effected by"); len(found) != 5 {
+		t.Errorf("expected all effectors, got %+v", found)
+	}
+	if found := manager.Creators(fieldIllustrator, "hide0"); len(found) != 1 || found[0].Name != "hideo" || found[0].Score >= 1 {
+		t.Errorf("expected similar illustrator, got %+v", found)
+	}
+
+	name, charts, ok := manager.CreatorCharts(fieldEffector, "EFFECTED BY A")
+	if !ok || name != "Effected by A" || len(charts) != 6 {
+		t.Fatalf("unexpected effector charts %s %+v", name, charts)
+	}
+	for i := 1; i < len(charts); i++ {
+		if charts[i].Level < charts[i-1].Level {
+			t.Errorf("charts should be ordered by level: %+v", charts)
+		}
+	}
+	if _, _, ok := manager.CreatorCharts(fieldIllustrator, "nobody"); ok {
+		t.Error("expected unknown illustrator")
+	}
+}
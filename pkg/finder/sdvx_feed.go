@@ -0,0 +1,66 @@
+package finder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SDVXNewSong 新曲
+type SDVXNewSong struct {
+	Id               int32  `json:"id"`
+	Title            string `json:"title"`
+	Artist           string `json:"artist"`
+	Version          string `json:"version"`
+	DistributionDate uint32 `json:"distribution_date"` // 发布日期 YYYYMMDD
+}
+
+// SDVXNewSongMonth 某个月发布的新曲
+type SDVXNewSongMonth struct {
+	Month string        `json:"month"` // YYYY-MM
+	Count int           `json:"count"`
+	Songs []SDVXNewSong `json:"songs"` // 按发布日期从新到旧, 同一天按id排列
+}
+
+// parseSDVXDate 日期转换为music_db中的YYYYMMDD, 支持 20240101 / 2024-01-01 / 2024/01/01
+func parseSDVXDate(s string) (uint32, error) {
+	date := strings.NewReplacer("-", "", "/", "").Replace(strings.TrimSpace(s))
+	if _, err := time.Parse("20060102", date); err != nil {
+		return 0, fmt.Errorf("invalid date: %s", s)
+	}
+	value, _ := strconv.ParseUint(date, 10, 32)
+	return uint32(value), nil
+}
+
+// NewSongs 发布日期在[since, until]内的曲目, 按月分组(从新到旧), until为0表示不限
+func (manager *SDVXManager) NewSongs(since, until uint32) []SDVXNewSongMonth {
+	songs := make([]SDVXNewSong, 0)
+	for id, music := range manager.musics() {
+		date := music.DistributionDate
+		if date < since || (until != 0 && date > until) {
+			continue
+		}
+		songs = append(songs, SDVXNewSong{Id: id, Title: music.TitleName, Artist: music.ArtistName, Version: music.Version, DistributionDate: date})
+	}
+
+	sort.Slice(songs, func(i, j int) bool {
+		if songs[i].DistributionDate != songs[j].DistributionDate {
+			return songs[i].DistributionDate > songs[j].DistributionDate
+		}
+		return songs[i].Id < songs[j].Id
+	})
+
+	months := make([]SDVXNewSongMonth, 0)
+	for _, song := range songs {
+		month := fmt.Sprintf("%04d-%02d", song.DistributionDate/10000, song.DistributionDate/100%100)
+		if len(months) == 0 || months[len(months)-1].Month != month {
+			months = append(months, SDVXNewSongMonth{Month: month, Songs: make([]SDVXNewSong, 0)})
+		}
+		last := &months[len(months)-1]
+		last.Songs = append(last.Songs, song)
+		last.Count++
+	}
+	return months
+}
@@ -0,0 +1,32 @@
+package finder
+
+import "testing"
+
+func TestSDVXNewSongs(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	months := manager.NewSongs(20200101, 0)
+	if len(months) != 3 || months[0].Month != "2022-01" || months[0].Songs[0].Id != 6 || months[2].Month != "2020-09" || months[2].Count != 1 {
+		t.Fatalf("unexpected feed %+v", months)
+	}
+
+	if months = manager.NewSongs(20200101, 20211231); len(months) != 2 || months[0].Month != "2021-12" {
+		t.Errorf("unexpected feed until 2021 %+v", months)
+	}
+	if months = manager.NewSongs(20300101, 0); len(months) != 0 {
+		t.Errorf("expected empty feed %+v", months)
+	}
+}
+
+func TestParseSDVXDate(t *testing.T) {
+	for _, s := range []string{"20240105", "2024-01-05", "2024/01/05"} {
+		if date, err := parseSDVXDate(s); err != nil || date != 20240105 {
+			t.Errorf("parse %s: %d %v", s, date, err)
+		}
+	}
+	for _, s := range []string{"", "2024", "20241301", "yesterday"} {
+		if _, err := parseSDVXDate(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -1,20 +1,18 @@
 package finder
 
 import (
-	"bytes"
-	"encoding/json"
+	"finder/pkg/search"
+	"finder/pkg/storage"
 	l "finder/pkg/util/log"
 	"fmt"
-	"github.com/clbanning/mxj/v2"
-	"golang.org/x/text/encoding/japanese"
-	"golang.org/x/text/transform"
-	"io"
 	"log"
 	"os"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type RadarInfo struct {
@@ -69,14 +67,231 @@ type SDVXMusicInfo struct {
 	DiffVer4         string                    `json:"diff_ver4"`         // 第4难度追加版本
 	Difficulties     map[string]DifficultyInfo `json:"difficulties"`      // 难度信息
 	DifficultyList   []string                  `json:"difficulty_list"`   // 可选难度列表
+	Raw              *SDVXRawText              `json:"raw,omitempty"`     // 替代字替换前的原始文本(调试用)
+	Source           string                    `json:"source"`            // 来自哪个数据库文件
 }
 
+// sdvxCatalog SDVX曲库快照
+// 构建完成后只读, 重载时整体替换
+type sdvxCatalog struct {
+	musics map[int32]SDVXMusicInfo
+	index  *search.Index // 曲名索引
+	romaji *search.Index // 读音罗马音和ascii曲名索引
+	charts []SDVXChart   // 全部谱面(按等级、id、难度槽排列)
+
+	illustrators *sdvxCreatorIndex // 曲绘画师索引
+	effectors    *sdvxCreatorIndex // 谱师索引
+}
+
+// buildIndex 建立曲名索引和罗马音索引(按id顺序)
+func (c *sdvxCatalog) buildIndex(fold func(string) string) {
+	ids := make([]int32, 0, len(c.musics))
+	for id := range c.musics {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	builder := search.NewBuilder(fold)
+	romaji := search.NewBuilder(fold)
+	for _, id := range ids {
+		music := c.musics[id]
+		builder.Add(int64(id), fieldTitle, music.TitleName)
+		romaji.AddRomaji(int64(id), fieldYomigana, music.TitleYomigana)
+		// ascii和曲名相同时(英文曲)曲名索引已经能查到
+		if music.Ascii != music.TitleName {
+			romaji.Add(int64(id), fieldAscii, music.Ascii)
+		}
+	}
+	c.index = builder.Build()
+	c.romaji = romaji.Build()
+}
+
+// sdvxAliasTable SDVX别名快照
+// 构建完成后只读, 修改时复制后整体替换
+type sdvxAliasTable struct {
+	aliases map[string][]string
+	index   *search.Index // 别名索引
+	pinyin  *search.Index // 别名拼音索引
+}
+
+// newSDVXAliasTable 由别名表建立快照、索引和拼音索引(按id顺序)
+func newSDVXAliasTable(aliases map[string][]string, fold func(string) string) *sdvxAliasTable {
+	ids := make([]string, 0, len(aliases))
+	for sid := range aliases {
+		ids = append(ids, sid)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		if a != b {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+
+	builder := search.NewBuilder(fold)
+	pinyin := search.NewBuilder(fold)
+	for _, sid := range ids {
+		id, _ := strconv.Atoi(sid)
+		for _, alias := range aliases[sid] {
+			builder.Add(int64(id), fieldAlias, alias)
+			pinyin.AddPinyin(int64(id), alias)
+		}
+	}
+
+	return &sdvxAliasTable{aliases: aliases, index: builder.Build(), pinyin: pinyin.Build()}
+}
+
+// validate 校验快照是否可用
+func (c *sdvxCatalog) validate() error {
+	if len(c.musics) == 0 {
+		return fmt.Errorf("sdvx catalog is empty")
+	}
+	return nil
+}
+
+// SDVXManager SDVX曲库和别名管理
+// 并发模型: 曲库和别名表都是只读快照, 读操作无锁直接读取当前快照;
+// 写操作(增删别名/重载)由m串行化, 复制出新表修改后整体替换
 type SDVXManager struct {
-	SDVXMusicInfos map[int32]SDVXMusicInfo
-	SDVXAliases    map[string][]string
-	logger         *l.Log
-	AliasesPath    string
-	m              sync.RWMutex
+	catalog atomic.Pointer[sdvxCatalog]    // 当前曲库快照
+	aliases atomic.Pointer[sdvxAliasTable] // 当前别名快照
+	report  atomic.Pointer[SDVXLoadReport] // 最近一次加载报告
+	logger  *l.Log
+	store   storage.AliasStore // 别名存储, 持有m时读写
+	m       sync.Mutex         // 串行化写操作
+
+	similarityThreshold float64             // 相似度匹配阈值, 启动时设置
+	registry            *sdvxRegistry       // 版本名和难度配置, 启动时设置
+	fixup               *strings.Replacer   // 替代字替换, 启动时设置
+	dbFiles             []string            // 按顺序叠加的数据库文件, 启动时设置
+	precedence          string              // 同一id的取舍规则, 启动时设置
+	normalize           func(string) string // 归一化函数, 启动时设置
+}
+
+// normalizer 曲名和别名的归一化函数
+func (manager *SDVXManager) normalizer() func(string) string {
+	if manager.normalize != nil {
+		return manager.normalize
+	}
+	return defaultNormalize
+}
+
+// sdvxRegistry 版本名和难度配置
+func (manager *SDVXManager) sdvxRegistry() *sdvxRegistry {
+	if manager.registry != nil {
+		return manager.registry
+	}
+	return defaultSDVXRegistry
+}
+
+// musicDBFiles 按顺序叠加的数据库文件, 没有配置时为music_db.xml
+func (manager *SDVXManager) musicDBFiles() []string {
+	if len(manager.dbFiles) != 0 {
+		return manager.dbFiles
+	}
+	return []string{"music_db.xml"}
+}
+
+// dbPrecedence 同一id的取舍规则, 没有配置时为override
+func (manager *SDVXManager) dbPrecedence() string {
+	if manager.precedence != "" {
+		return manager.precedence
+	}
+	return SDVXPrecedenceOverride
+}
+
+// charFixup 替代字替换
+func (manager *SDVXManager) charFixup() *strings.Replacer {
+	if manager.fixup != nil {
+		return manager.fixup
+	}
+	return defaultSDVXFixup
+}
+
+// musics 当前曲库快照中的曲目
+func (manager *SDVXManager) musics() map[int32]SDVXMusicInfo {
+	if c := manager.catalog.Load(); c != nil {
+		return c.musics
+	}
+	return nil
+}
+
+// titleIndex 当前曲名索引
+func (manager *SDVXManager) titleIndex() *search.Index {
+	if c := manager.catalog.Load(); c != nil {
+		return c.index
+	}
+	return nil
+}
+
+// romajiIndex 当前罗马音索引
+func (manager *SDVXManager) romajiIndex() *search.Index {
+	if c := manager.catalog.Load(); c != nil {
+		return c.romaji
+	}
+	return nil
+}
+
+// aliasMap 当前别名快照(只读)
+func (manager *SDVXManager) aliasMap() map[string][]string {
+	if table := manager.aliases.Load(); table != nil {
+		return table.aliases
+	}
+	return nil
+}
+
+// aliasIndex 当前别名索引
+func (manager *SDVXManager) aliasIndex() *search.Index {
+	if table := manager.aliases.Load(); table != nil {
+		return table.index
+	}
+	return nil
+}
+
+// aliasPinyinIndex 当前别名拼音索引
+func (manager *SDVXManager) aliasPinyinIndex() *search.Index {
+	if table := manager.aliases.Load(); table != nil {
+		return table.pinyin
+	}
+	return nil
+}
+
+// MatchAliasPinyin 通过拼音或拼音首字母匹配别名
+// 例: yunchuan / yc -> 晕船
+func (manager *SDVXManager) MatchAliasPinyin(query string) []SDVXScoredMatch {
+	hits := manager.aliasPinyinIndex().MatchPinyin(query)
+
+	matches := make([]SDVXScoredMatch, 0, len(hits))
+	for _, hit := range hits {
+		matches = append(matches, SDVXScoredMatch{Id: int32(hit.Doc), Text: hit.Text, Field: hit.Field, IsAlias: true, Score: containScore(query, hit.Key)})
+	}
+
+	return matches
+}
+
+// MatchRomaji 通过罗马音匹配曲名读音(title_yomigana)或ascii曲名
+// 例: kamisama -> 神様のいたずら(カミサマノイタズラ)
+func (manager *SDVXManager) MatchRomaji(query string) []SDVXScoredMatch {
+	index := manager.romajiIndex()
+	hits := index.MatchRomaji(query)
+
+	matches := make([]SDVXScoredMatch, 0, len(hits))
+	for _, hit := range hits {
+		score := containScore(index.Fold(search.Romaji(query)), index.Fold(hit.Key))
+		matches = append(matches, SDVXScoredMatch{Id: int32(hit.Doc), Text: hit.Text, Field: hit.Field, Score: score})
+	}
+
+	return matches
+}
+
+// cloneAliases 复制别名表, 用于写时复制
+func cloneAliases(aliases map[string][]string) map[string][]string {
+	next := make(map[string][]string, len(aliases)+1)
+	for sid, aliasList := range aliases {
+		next[sid] = aliasList
+	}
+	return next
 }
 
 // logf 打印日志(如果没有启用则打到控制台)
@@ -101,198 +316,134 @@ func (manager *SDVXManager) panic(v any) {
 	panic(v)
 }
 
-var SDVXVersionName = []string{"", "Booth", "Infinite Infection", "Gravity Wars", "Heavenly Haven", "Vivid Wave", "Exceed Gear"}
-
-// LoadData 加载数据库
-func (manager *SDVXManager) LoadData(DBPath string) error {
-	manager.m.RLock()
-	defer manager.m.RUnlock()
-	manager.SDVXMusicInfos = make(map[int32]SDVXMusicInfo)
-	// 打开文件
-	file, err := os.Open(DBPath)
+// LoadData 按顺序加载并叠加数据库, 同一id按取舍规则合并
+// 在旁路构建完整快照, 校验通过后一次性替换, 失败时继续使用旧快照
+// 有问题的曲目跳过或取默认值, 问题和多个文件间的id冲突记录在加载报告中
+func (manager *SDVXManager) LoadData(DBPaths ...string) error {
+	report := &SDVXLoadReport{
+		Files:      DBPaths,
+		Time:       time.Now(),
+		Precedence: manager.dbPrecedence(),
+		Conflicts:  make([]SDVXLoadConflict, 0),
+		Warnings:   make([]SDVXLoadWarning, 0),
+	}
+	err := manager.loadData(DBPaths, report)
 	if err != nil {
-		return err
+		report.Error = err.Error()
 	}
-	defer func(file *os.File) {
-		err := file.Close()
+	manager.report.Store(report)
+	return err
+}
+
+// loadData 解析数据库并替换快照
+func (manager *SDVXManager) loadData(DBPaths []string, report *SDVXLoadReport) error {
+	if len(DBPaths) == 0 {
+		return fmt.Errorf("no sdvx db file")
+	}
+
+	catalog := &sdvxCatalog{musics: make(map[int32]SDVXMusicInfo)}
+	sources := make(map[int32][]string)
+
+	parser := &sdvxParser{registry: manager.sdvxRegistry(), fixup: manager.charFixup(), warnings: make([]SDVXLoadWarning, 0)}
+	for _, DBPath := range DBPaths {
+		musics, err := manager.readData(DBPath, parser, report)
 		if err != nil {
-			manager.panic(err)
+			return fmt.Errorf("%s: %w", DBPath, err)
 		}
-	}(file)
 
-	shiftJISReader := transform.NewReader(file, japanese.ShiftJIS.NewDecoder())
+		for id, music := range musics {
+			sources[id] = append(sources[id], DBPath)
+			if _, exist := catalog.musics[id]; exist && report.Precedence == SDVXPrecedenceKeep {
+				continue
+			}
+			catalog.musics[id] = music
+		}
+	}
 
-	// var reader io.Reader = file
-	var buf bytes.Buffer
-	_, err = io.Copy(&buf, shiftJISReader)
-	if err != nil {
-		return err
+	for id, files := range sources {
+		if len(files) > 1 {
+			music := catalog.musics[id]
+			report.Conflicts = append(report.Conflicts, SDVXLoadConflict{Id: id, Title: music.TitleName, Sources: files, Source: music.Source})
+		}
 	}
+	sort.Slice(report.Conflicts, func(i, j int) bool { return report.Conflicts[i].Id < report.Conflicts[j].Id })
 
-	// 获取文件内容并删除 XML 声明
-	content := buf.Bytes()
-	re := regexp.MustCompile(`(?i)^\s*<\?xml[^?>]+\?>\s*`)
-	contentWithoutXMLDecl := re.ReplaceAll(content, []byte{})
+	report.Loaded = len(catalog.musics)
+	report.Warnings = parser.warnings
 
-	mv, err := mxj.NewMapXmlReader(bytes.NewReader(contentWithoutXMLDecl))
-	if err != nil {
+	if err := catalog.validate(); err != nil {
 		return err
 	}
 
-	LevelMapper := map[string]string{
-		"nov": "novice",
-		"adv": "advanced",
-		"exh": "exhaust",
-		"inf": "infinite",
-		"grv": "infinite",
-		"hvn": "infinite",
-		"vvd": "infinite",
-		"xcd": "infinite",
-		"mxm": "maximum",
-		"ult": "ultimate",
-	}
-
-	musicList := mv["mdb"].(map[string]any)["music"].([]any)
-	for _, music := range musicList {
-		infoAll := music.(map[string]any)
-		idx, _ := strconv.Atoi(infoAll["-id"].(string))
-		id := int32(idx)
-		var Info SDVXMusicInfo
-		Info.Id = id
-
-		musicInfo := infoAll["info"].(map[string]any)
-
-		// 第四难度版本
-		infVerText, _ := strconv.Atoi(musicInfo["inf_ver"].(map[string]any)["#text"].(string))
-		infVer := uint8(infVerText)
-
-		Info.DiffVer4 = SDVXVersionName[infVer]
-		// Info.Label = musicInfo["label"].(string)
-		Info.TitleName = musicInfo["title_name"].(string)
-		Info.TitleYomigana = musicInfo["title_yomigana"].(string)
-		Info.Ascii = musicInfo["ascii"].(string)
-		Info.ArtistName = musicInfo["artist_name"].(string)
-		Info.ArtistYomigana = musicInfo["artist_yomigana"].(string)
-
-		musicVersion, _ := strconv.Atoi(musicInfo["version"].(map[string]any)["#text"].(string))
-		volume, _ := strconv.Atoi(musicInfo["volume"].(map[string]any)["#text"].(string))
-		isFixed, _ := strconv.Atoi(musicInfo["is_fixed"].(map[string]any)["#text"].(string))
-		genre, _ := strconv.Atoi(musicInfo["genre"].(map[string]any)["#text"].(string))
-		distributionDate, _ := strconv.Atoi(musicInfo["distribution_date"].(map[string]any)["#text"].(string))
-		demoPri, _ := strconv.Atoi(musicInfo["demo_pri"].(map[string]any)["#text"].(string))
-		bpmMin, _ := strconv.Atoi(musicInfo["bpm_min"].(map[string]any)["#text"].(string))
-		bpmMax, _ := strconv.Atoi(musicInfo["bpm_max"].(map[string]any)["#text"].(string))
-		bgNo, _ := strconv.Atoi(musicInfo["bg_no"].(map[string]any)["#text"].(string))
-
-		Info.Version = SDVXVersionName[uint8(musicVersion)]
-		Info.Volume = uint16(volume)
-		Info.IsFixed = isFixed != 0
-		Info.Genre = uint32(genre)
-		Info.DistributionDate = uint32(distributionDate)
-		Info.DemoPri = int8(demoPri)
-		Info.BPMMax = float32(bpmMax) / 100.0
-		Info.BPMMin = float32(bpmMin) / 100.0
-		Info.BGNo = uint16(bgNo)
-
-		// 难度列表
-		difficultyList := make([]string, 0)
-		difficulties := infoAll["difficulty"].(map[string]any)
-
-		_, noviceExist := difficulties["novice"]
-		_, advancedExist := difficulties["advanced"]
-		_, exhaustExist := difficulties["exhaust"]
-		_, infiniteExist := difficulties["infinite"]
-		_, maximumExist := difficulties["maximum"]
-		_, ultimateExist := difficulties["ultimate"]
-
-		if noviceExist {
-			difficultyList = append(difficultyList, "nov")
-		}
-		if advancedExist {
-			difficultyList = append(difficultyList, "adv")
-		}
-		if exhaustExist {
-			difficultyList = append(difficultyList, "exh")
-		}
-		if infiniteExist {
-			if infVer == 2 {
-				difficultyList = append(difficultyList, "inf")
-			} else if infVer == 3 {
-				difficultyList = append(difficultyList, "grv")
-			} else if infVer == 4 {
-				difficultyList = append(difficultyList, "hvn")
-			} else if infVer == 5 {
-				difficultyList = append(difficultyList, "vvd")
-			} else if infVer == 6 {
-				difficultyList = append(difficultyList, "xcd")
-			}
-		}
-		if maximumExist {
-			difficultyList = append(difficultyList, "mxm")
-		}
-		if ultimateExist {
-			difficultyList = append(difficultyList, "ult")
-		}
+	catalog.buildIndex(manager.normalizer())
+	catalog.buildCharts()
+	catalog.buildCreators(manager.normalizer())
+	manager.catalog.Store(catalog)
 
-		Info.Difficulties = make(map[string]DifficultyInfo)
-
-		for _, levelKey := range difficultyList {
-			levelKeyFull := LevelMapper[levelKey]
-			var DifficultyInfos DifficultyInfo
-			difficultyInfo := difficulties[levelKeyFull].(map[string]any)
-
-			diffNum, _ := strconv.Atoi(difficultyInfo["difnum"].(map[string]any)["#text"].(string))
-			jacketMask, _ := strconv.Atoi(difficultyInfo["jacket_mask"].(map[string]any)["#text"].(string))
-			jacketPrint, _ := strconv.Atoi(difficultyInfo["jacket_print"].(map[string]any)["#text"].(string))
-			limited, _ := strconv.Atoi(difficultyInfo["limited"].(map[string]any)["#text"].(string))
-			price, _ := strconv.Atoi(difficultyInfo["price"].(map[string]any)["#text"].(string))
-
-			DifficultyInfos.Level = uint8(diffNum)
-			DifficultyInfos.JacketMask = int32(jacketMask)
-			DifficultyInfos.JacketPrint = int32(jacketPrint)
-			DifficultyInfos.Limited = uint8(limited)
-			DifficultyInfos.Price = int32(price)
-			DifficultyInfos.EffectedBy = difficultyInfo["effected_by"].(string)
-			DifficultyInfos.Illustrator = difficultyInfo["illustrator"].(string)
-
-			DifficultyInfos.MaxExscore = 0
-			DifficultyInfos.Radar = NewRadarInfo()
-
-			maxExscoreMap, extendExist := difficultyInfo["max_exscore"]
-			if extendExist {
-				maxExscore, _ := strconv.Atoi(maxExscoreMap.(map[string]any)["#text"].(string))
-				DifficultyInfos.MaxExscore = int32(maxExscore)
-				radar := difficultyInfo["radar"].(map[string]any)
-				handTrip, _ := strconv.Atoi(radar["hand-trip"].(map[string]any)["#text"].(string))
-				oneHand, _ := strconv.Atoi(radar["one-hand"].(map[string]any)["#text"].(string))
-				notes, _ := strconv.Atoi(radar["notes"].(map[string]any)["#text"].(string))
-				peak, _ := strconv.Atoi(radar["peak"].(map[string]any)["#text"].(string))
-				tricky, _ := strconv.Atoi(radar["tricky"].(map[string]any)["#text"].(string))
-				tsumami, _ := strconv.Atoi(radar["tsumami"].(map[string]any)["#text"].(string))
-
-				DifficultyInfos.Radar.HandTrip = uint8(handTrip)
-				DifficultyInfos.Radar.OneHand = uint8(oneHand)
-				DifficultyInfos.Radar.Notes = uint8(notes)
-				DifficultyInfos.Radar.Peak = uint8(peak)
-				DifficultyInfos.Radar.Tricky = uint8(tricky)
-				DifficultyInfos.Radar.Tsumami = uint8(tsumami)
-			}
+	manager.logln("sdvx db loaded:", len(catalog.musics))
+	if len(parser.warnings) > 0 {
+		manager.logln("sdvx db warnings:", len(parser.warnings), "skipped:", report.Skipped)
+	}
+	if len(report.Conflicts) > 0 {
+		manager.logln("sdvx db conflicts:", len(report.Conflicts), "precedence:", report.Precedence)
+	}
+	return nil
+}
+
+// readData 解析一个数据库文件, 文件内重复的id只保留第一个
+func (manager *SDVXManager) readData(DBPath string, parser *sdvxParser, report *SDVXLoadReport) (map[int32]SDVXMusicInfo, error) {
+	musics := make(map[int32]SDVXMusicInfo)
+	// 打开文件
+	file, err := os.Open(DBPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			manager.panic(err)
+		}
+	}(file)
 
-			Info.Difficulties[levelKey] = DifficultyInfos
+	parser.file = DBPath
+	err = decodeMusicDB(file, func(music *mdbMusic) {
+		Info, ok := parser.music(music)
+		if !ok {
+			report.Skipped++
+			return
+		}
+		if _, exist := musics[Info.Id]; exist {
+			parser.warn("id", "duplicate id, skipped")
+			report.Skipped++
+			return
 		}
 
-		Info.DifficultyList = difficultyList
+		Info.Source = DBPath
+		musics[Info.Id] = Info
+	})
+	if err != nil {
+		return nil, err
+	}
+	return musics, nil
+}
 
-		manager.SDVXMusicInfos[id] = Info
+// Conflicts 最近一次加载时多个数据库文件间的id冲突, 没有加载过时为空
+func (manager *SDVXManager) Conflicts() []SDVXLoadConflict {
+	if report := manager.report.Load(); report != nil {
+		return report.Conflicts
 	}
+	return make([]SDVXLoadConflict, 0)
+}
 
-	manager.logln("sdvx db loaded")
-	return nil
+// LoadReport 最近一次加载数据库的报告, 没有加载过时为nil
+func (manager *SDVXManager) LoadReport() *SDVXLoadReport {
+	return manager.report.Load()
 }
 
 // GetAll 获取全部曲目信息
 func (manager *SDVXManager) GetAll() *map[int32]SDVXMusicInfo {
-	return &manager.SDVXMusicInfos
+	musics := manager.musics()
+	return &musics
 }
 
 // Get 通过ID获取曲目信息
@@ -314,7 +465,7 @@ func (manager *SDVXManager) Get(id any) (*SDVXMusicInfo, error) {
 		return nil, fmt.Errorf("id type error")
 	}
 
-	musicInfo, exists := manager.SDVXMusicInfos[sid]
+	musicInfo, exists := manager.musics()[sid]
 	if !exists {
 		return nil, fmt.Errorf("music info with id %d not found", sid)
 	}
@@ -341,66 +492,57 @@ func (manager *SDVXManager) Exist(id any) (bool, error) {
 		return false, fmt.Errorf("id type error")
 	}
 
-	_, exists := manager.SDVXMusicInfos[sid]
+	_, exists := manager.musics()[sid]
 	return exists, nil
 }
 
 // Match 曲目匹配
 // query 匹配的名称
-// isNoCase 禁用大小写
+// isNoCase 忽略大小写(同时按归一化配置忽略全角/假名/标点等差异)
 // isFuzzy 模糊匹配
 func (manager *SDVXManager) Match(query string, isNoCase bool, isFuzzy bool) []int32 {
-	if isNoCase {
-		query = strings.ToLower(query)
-	}
 	var matches []int32
 
-	for id, value := range manager.SDVXMusicInfos {
-		title := value.TitleName
-		if isNoCase {
-			title = strings.ToLower(title)
-		}
-		if isFuzzy {
-			if strings.Contains(title, query) {
-				matches = append(matches, id)
-			}
-		} else {
-			if title == query {
-				matches = append(matches, id)
-			}
-		}
+	for _, hit := range lookup(manager.titleIndex(), query, isNoCase, isFuzzy) {
+		matches = append(matches, int32(hit.Doc))
 	}
 
 	return matches
 }
 
-// LoadAliases 加载别名
-func (manager *SDVXManager) LoadAliases(aliasesPath string) error {
-	manager.m.RLock()
-	defer manager.m.RUnlock()
-	file, err := os.Open(aliasesPath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %v", err)
+// lookup 按匹配方式查索引
+func lookup(index *search.Index, query string, isNoCase, isFuzzy bool) []search.Hit {
+	switch {
+	case isFuzzy && isNoCase:
+		return index.ContainsFold(query)
+	case isFuzzy:
+		return index.Contains(query)
+	case isNoCase:
+		return index.ExactFold(query)
+	default:
+		return index.Exact(query)
 	}
+}
 
-	manager.AliasesPath = aliasesPath
+// LoadAliases 从json文件加载别名
+// 解析成功后才替换旧别名表, 之后的修改写回该文件
+func (manager *SDVXManager) LoadAliases(aliasesPath string) error {
+	return manager.LoadAliasStore(storage.NewJSON("", aliasesPath))
+}
 
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			manager.panic(err)
-		}
-	}(file)
+// LoadAliasStore 从存储加载别名
+// 解析成功后才替换旧别名表, 之后的修改写入该存储
+func (manager *SDVXManager) LoadAliasStore(store storage.AliasStore) error {
+	manager.m.Lock()         // 与写别名互斥, 避免读到写了一半的文件
+	defer manager.m.Unlock() // 释放写锁
 
-	fileContent, err := io.ReadAll(file)
+	aliases, err := store.LoadAliases()
 	if err != nil {
-		return fmt.Errorf("failed to read file: %v", err)
+		return err
 	}
 
-	err = json.Unmarshal(fileContent, &manager.SDVXAliases)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %v", err)
-	}
+	manager.store = store
+	manager.aliases.Store(newSDVXAliasTable(aliases, manager.normalizer()))
 
 	manager.logln("sdvx aliases loaded")
 	return nil
@@ -416,21 +558,28 @@ const ( // 3 无法找到这个别名
 	EmptyString
 )
 
-// saveAliases 将 SDVXAliases 数据写入 JSON 文件
-func (manager *SDVXManager) saveAliases() error {
-	// 将 SDVXAliases 数据编码为 JSON 格式
-	data, err := json.MarshalIndent(manager.SDVXAliases, "", "  ")
-	if err != nil {
-		return fmt.Errorf("unable to marshal JSON: %v", err)
+// saveAliases 将一个别名的变更写入存储
+// 调用方需持有m
+func (manager *SDVXManager) saveAliases(aliases map[string][]string, sid, alias string) error {
+	if manager.store == nil {
+		return fmt.Errorf("aliases store not loaded")
 	}
 
-	// 写入文件
-	err = os.WriteFile(manager.AliasesPath, data, 0644)
-	if err != nil {
-		return fmt.Errorf("unable to write file: %v", err)
-	}
+	return manager.store.SaveAlias(aliases, sid, alias)
+}
 
-	return nil
+// toAliasKey 将曲目id转换为别名表的key
+func toAliasKey(id any) (string, error) {
+	switch v := id.(type) {
+	case int:
+		return strconv.Itoa(v), nil
+	case int32:
+		return strconv.Itoa(int(v)), nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("id type error")
+	}
 }
 
 // AddAlias 添加别名
@@ -440,17 +589,9 @@ func (manager *SDVXManager) AddAlias(id any, newAlias string) (int, error) {
 
 	newAlias = strings.TrimSpace(newAlias) // 去除首尾空格
 
-	var sid string
-
-	switch v := id.(type) {
-	case int:
-		sid = strconv.Itoa(v)
-	case int32:
-		sid = strconv.Itoa(int(v))
-	case string:
-		sid = v
-	default:
-		return UnknownError, fmt.Errorf("id type error")
+	sid, err := toAliasKey(id)
+	if err != nil {
+		return UnknownError, err
 	}
 
 	exist, err := manager.Exist(sid)
@@ -459,31 +600,26 @@ func (manager *SDVXManager) AddAlias(id any, newAlias string) (int, error) {
 		return MusicIDNotExist, err
 	}
 
-	_, isNotEmpty := manager.SDVXAliases[sid]
-
-	if !isNotEmpty {
-		manager.SDVXAliases[sid] = make([]string, 0)
-		err = manager.saveAliases()
-		if err != nil {
-			return UnknownError, err
-		}
+	// 归一化后相同的别名视为重复
+	if len(manager.aliasIndex().ExactFold(newAlias)) != 0 {
+		return AliasAlreadyExists, fmt.Errorf("alias already exists")
 	}
 
-	for _, aliasList := range manager.SDVXAliases {
-		for _, alias := range aliasList {
-			if newAlias == alias {
-				return AliasAlreadyExists, fmt.Errorf("alias already exists")
-			}
-		}
-	}
+	old := manager.aliasMap()
 
-	// 修改数据
-	manager.SDVXAliases[sid] = append(manager.SDVXAliases[sid], newAlias)
+	// 修改数据(复制后替换, 不改动旧快照)
+	aliases := cloneAliases(old)
+	aliasList := make([]string, 0, len(old[sid])+1)
+	aliasList = append(aliasList, old[sid]...)
+	aliases[sid] = append(aliasList, newAlias)
 
-	err = manager.saveAliases()
+	// 先落盘, 成功后再替换快照, 失败时内存和文件保持一致
+	err = manager.saveAliases(aliases, sid, newAlias)
 	if err != nil {
 		return UnknownError, err
 	}
+
+	manager.aliases.Store(newSDVXAliasTable(aliases, manager.normalizer()))
 	return Success, nil
 }
 
@@ -492,39 +628,33 @@ func (manager *SDVXManager) DelAlias(delAlias string) (int, error) {
 	manager.m.Lock()         // 获取写锁
 	defer manager.m.Unlock() // 释放写锁
 
-	for sid, aliasList := range manager.SDVXAliases {
+	old := manager.aliasMap()
+	for sid, aliasList := range old {
 		for index, alias := range aliasList {
 			if delAlias == alias {
-				manager.SDVXAliases[sid] = append(aliasList[:index], aliasList[index+1:]...)
-				goto final
+				aliases := cloneAliases(old)
+				rest := make([]string, 0, len(aliasList)-1)
+				rest = append(rest, aliasList[:index]...)
+				aliases[sid] = append(rest, aliasList[index+1:]...)
+
+				if err := manager.saveAliases(aliases, sid, delAlias); err != nil {
+					return UnknownError, err
+				}
+
+				manager.aliases.Store(newSDVXAliasTable(aliases, manager.normalizer()))
+				return Success, nil
 			}
 		}
 	}
 
 	return NotFoundAlias, fmt.Errorf("alias not found")
-
-final:
-	err := manager.saveAliases()
-	if err != nil {
-		return UnknownError, err
-	}
-
-	return Success, nil
 }
 
 // GetAlias 通过曲目id获取别名
 func (manager *SDVXManager) GetAlias(id any) ([]string, int, error) {
-	var sid string
-
-	switch v := id.(type) {
-	case int:
-		sid = strconv.Itoa(v)
-	case int32:
-		sid = strconv.Itoa(int(v))
-	case string:
-		sid = v
-	default:
-		return nil, UnknownError, fmt.Errorf("id type error")
+	sid, err := toAliasKey(id)
+	if err != nil {
+		return nil, UnknownError, err
 	}
 
 	exist, err := manager.Exist(sid)
@@ -533,133 +663,192 @@ func (manager *SDVXManager) GetAlias(id any) ([]string, int, error) {
 		return nil, MusicIDNotExist, err
 	}
 
-	_, isNotEmpty := manager.SDVXAliases[sid]
-
+	aliasList, isNotEmpty := manager.aliasMap()[sid]
 	if !isNotEmpty {
-		manager.SDVXAliases[sid] = make([]string, 0)
-		manager.m.Lock()
-		err = manager.saveAliases()
-		manager.m.Unlock()
-		if err != nil {
-			return nil, UnknownError, err
-		}
+		aliasList = make([]string, 0)
 	}
 
-	return manager.SDVXAliases[sid], Success, err
+	return aliasList, Success, nil
 }
 
 // GetAliases 获取全部别名信息
+// 返回的是只读快照, 不可修改
 func (manager *SDVXManager) GetAliases() *map[string][]string {
-	return &manager.SDVXAliases
+	aliases := manager.aliasMap()
+	return &aliases
 }
 
 // MatchAlias 曲目匹配
 // query 匹配的别名
-// isNoCase 禁用大小写
+// isNoCase 忽略大小写(同时按归一化配置忽略全角/假名/标点等差异)
 // isFuzzy 模糊匹配
 func (manager *SDVXManager) MatchAlias(query string, isNoCase, isFuzzy bool) []struct {
 	Id    int32
 	Alias string
 } {
-	// 如果需要忽略大小写，先将 query 转换为小写
-	if isNoCase {
-		query = strings.ToLower(query)
-	}
-
 	// 存储匹配结果
 	matches := make([]struct {
 		Id    int32
 		Alias string
 	}, 0)
 
-	// 遍历 Aliases map
-	for id, aliases := range manager.SDVXAliases {
-		sid, _ := strconv.Atoi(id)
-
-		for _, alias := range aliases {
-			originalAlias := alias // 保留原始 alias 以便返回
-			// 如果需要忽略大小写，转换 alias 为小写
-			if isNoCase {
-				alias = strings.ToLower(alias)
-			}
-
-			// 模糊匹配或完全匹配
-			if isFuzzy {
-				if strings.Contains(alias, query) {
-					matches = append(matches, struct {
-						Id    int32
-						Alias string
-					}{Id: int32(sid), Alias: originalAlias})
-				}
-			} else {
-				if alias == query {
-					matches = append(matches, struct {
-						Id    int32
-						Alias string
-					}{Id: int32(sid), Alias: originalAlias})
-				}
-			}
-		}
+	for _, hit := range lookup(manager.aliasIndex(), query, isNoCase, isFuzzy) {
+		matches = append(matches, struct {
+			Id    int32
+			Alias string
+		}{Id: int32(hit.Doc), Alias: hit.Text})
 	}
 
 	return matches
 }
 
-// SimpleMatch 简易匹配曲目(整合别名匹配+曲名匹配)
-func (manager *SDVXManager) SimpleMatch(query string) []int32 {
-	emptyList := make([]int32, 0)
-	ids := emptyList
+// SDVXScoredMatch 带分数的匹配结果
+type SDVXScoredMatch struct {
+	Id      int32   `json:"id"`       // 曲目id
+	Text    string  `json:"text"`     // 命中的文本(曲名/别名/读音/ascii)
+	Field   string  `json:"field"`    // 命中的字段(title/alias/pinyin/initials/title_yomigana/ascii)
+	IsAlias bool    `json:"is_alias"` // 是否通过别名命中
+	Score   float64 `json:"score"`    // 分数 0~1, 1为完全一致
+}
 
-	// 精确曲名获取
-	ids = manager.Match(query, false, false)
-	if len(ids) != 0 {
-		return ids
-	}
-	ids = emptyList
+// defaultSimilarityThreshold 默认相似度阈值
+const defaultSimilarityThreshold = 0.5
 
-	// 精确别名获取
-	idAliases := manager.MatchAlias(query, false, false)
-	for _, lists := range idAliases {
-		ids = append(ids, lists.Id)
+// threshold 相似度阈值
+func (manager *SDVXManager) threshold() float64 {
+	if manager.similarityThreshold > 0 {
+		return manager.similarityThreshold
 	}
-	if len(ids) != 0 {
-		return ids
+	return defaultSimilarityThreshold
+}
+
+// SimilarMatch 相似度匹配(容错), 返回相似度不低于阈值的候选, 按分数从高到低排列
+// query 匹配的名称
+// isAlias 匹配别名(否则匹配曲名)
+func (manager *SDVXManager) SimilarMatch(query string, isAlias bool) []SDVXScoredMatch {
+	threshold := manager.threshold()
+
+	index, field := manager.titleIndex(), fieldTitle
+	if isAlias {
+		index, field = manager.aliasIndex(), fieldAlias
 	}
-	ids = emptyList
+	query = index.Fold(query)
 
-	// 不区分大小写曲名获取
-	ids = manager.Match(query, true, false)
-	if len(ids) != 0 {
-		return ids
+	matches := make([]SDVXScoredMatch, 0)
+	index.Scan(func(hit search.Hit, folded string) bool {
+		if score := search.Similarity(query, folded); score >= threshold {
+			matches = append(matches, SDVXScoredMatch{Id: int32(hit.Doc), Text: hit.Text, Field: field, IsAlias: isAlias, Score: score})
+		}
+		return true
+	})
+
+	return bestMatches(matches)
+}
+
+// bestMatches 同一曲目只保留最高分, 按分数从高到低(同分按id)排列
+func bestMatches(matches []SDVXScoredMatch) []SDVXScoredMatch {
+	best := make(map[int32]int, len(matches))
+	result := make([]SDVXScoredMatch, 0, len(matches))
+	for _, match := range matches {
+		if index, ok := best[match.Id]; ok {
+			if match.Score > result[index].Score {
+				result[index] = match
+			}
+			continue
+		}
+		best[match.Id] = len(result)
+		result = append(result, match)
 	}
-	ids = emptyList
 
-	// 不区分大小写别名获取
-	idAliases = manager.MatchAlias(query, true, false)
-	for _, lists := range idAliases {
-		ids = append(ids, lists.Id)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
+
+// containScore 包含匹配的分数: 查询占命中文本的比例
+func containScore(query, text string) float64 {
+	textLen := len([]rune(text))
+	if textLen == 0 {
+		return 0
 	}
-	if len(ids) != 0 {
-		return ids
+	score := float64(len([]rune(query))) / float64(textLen)
+	if score > 1 {
+		score = 1
 	}
-	ids = emptyList
+	return score
+}
 
-	// 模糊曲名匹配
-	ids = manager.Match(query, true, true)
-	if len(ids) != 0 {
-		return ids
+// SimpleMatchScored 简易匹配曲目并给出分数
+// 依次尝试: 精确曲名 -> 精确别名 -> 忽略大小写曲名 -> 忽略大小写别名 -> 模糊曲名 -> 模糊别名 -> 别名拼音 -> 读音罗马音/ascii -> 相似度
+// 命中即返回
+func (manager *SDVXManager) SimpleMatchScored(query string) []SDVXScoredMatch {
+	musics := manager.musics()
+
+	titles := func(ids []int32) []SDVXScoredMatch {
+		matches := make([]SDVXScoredMatch, 0, len(ids))
+		for _, id := range ids {
+			title := musics[id].TitleName
+			matches = append(matches, SDVXScoredMatch{Id: id, Text: title, Field: fieldTitle, Score: containScore(query, title)})
+		}
+		return matches
 	}
-	ids = emptyList
 
-	// 模糊别名匹配
-	idAliases = manager.MatchAlias(query, true, true)
-	for _, lists := range idAliases {
-		ids = append(ids, lists.Id)
+	aliases := func(hits []struct {
+		Id    int32
+		Alias string
+	}) []SDVXScoredMatch {
+		matches := make([]SDVXScoredMatch, 0, len(hits))
+		for _, hit := range hits {
+			matches = append(matches, SDVXScoredMatch{Id: hit.Id, Text: hit.Alias, Field: fieldAlias, IsAlias: true, Score: containScore(query, hit.Alias)})
+		}
+		return matches
+	}
+
+	stages := []func() []SDVXScoredMatch{
+		// 精确曲名获取
+		func() []SDVXScoredMatch { return titles(manager.Match(query, false, false)) },
+		// 精确别名获取
+		func() []SDVXScoredMatch { return aliases(manager.MatchAlias(query, false, false)) },
+		// 不区分大小写曲名获取
+		func() []SDVXScoredMatch { return titles(manager.Match(query, true, false)) },
+		// 不区分大小写别名获取
+		func() []SDVXScoredMatch { return aliases(manager.MatchAlias(query, true, false)) },
+		// 模糊曲名匹配
+		func() []SDVXScoredMatch { return titles(manager.Match(query, true, true)) },
+		// 模糊别名匹配
+		func() []SDVXScoredMatch { return aliases(manager.MatchAlias(query, true, true)) },
+		// 别名拼音匹配
+		func() []SDVXScoredMatch { return manager.MatchAliasPinyin(query) },
+		// 读音罗马音/ascii曲名匹配
+		func() []SDVXScoredMatch { return manager.MatchRomaji(query) },
+		// 相似度匹配(容错)
+		func() []SDVXScoredMatch {
+			return append(manager.SimilarMatch(query, false), manager.SimilarMatch(query, true)...)
+		},
+	}
+
+	for _, stage := range stages {
+		if matches := stage(); len(matches) != 0 {
+			return bestMatches(matches)
+		}
 	}
-	if len(ids) != 0 {
-		return ids
+
+	return make([]SDVXScoredMatch, 0)
+}
+
+// SimpleMatch 简易匹配曲目(整合别名匹配+曲名匹配+相似度匹配)
+func (manager *SDVXManager) SimpleMatch(query string) []int32 {
+	matches := manager.SimpleMatchScored(query)
+
+	ids := make([]int32, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.Id)
 	}
-	ids = emptyList
 
 	return ids
 }
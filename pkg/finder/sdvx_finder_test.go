@@ -0,0 +1,597 @@
+package finder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestSDVXManager 加载testdata中的曲库, 别名复制到临时目录避免改动测试数据
+func newTestSDVXManager(t testing.TB) (*SDVXManager, string) {
+	t.Helper()
+
+	aliases, err := os.ReadFile(filepath.Join("testdata", "aliases.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aliasesPath := filepath.Join(t.TempDir(), "aliases.json")
+	if err := os.WriteFile(aliasesPath, aliases, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &SDVXManager{}
+	if err := manager.LoadData(filepath.Join("testdata", "music_db.xml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.LoadAliases(aliasesPath); err != nil {
+		t.Fatal(err)
+	}
+
+	return manager, aliasesPath
+}
+
+func TestSDVXManagerLoadKeepsOldCatalog(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	if err := manager.LoadData(filepath.Join("testdata", "not_exist.xml")); err == nil {
+		t.Fatal("expected load error")
+	}
+	if err := manager.LoadAliases(filepath.Join("testdata", "not_exist.json")); err == nil {
+		t.Fatal("expected load error")
+	}
+
+	if exist, _ := manager.Exist(1); !exist {
+		t.Fatal("old catalog should keep serving after a failed reload")
+	}
+	if ids := manager.SimpleMatch("晕船"); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("old aliases should keep serving after a failed reload, got %v", ids)
+	}
+}
+
+func TestSDVXManagerAddDelAliasParallel(t *testing.T) {
+	manager, aliasesPath := newTestSDVXManager(t)
+
+	const workers = 8
+	const perWorker = 20
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				alias := fmt.Sprintf("alias-%d-%d", w, i)
+				if status, err := manager.AddAlias(int32(w%6+1), alias); status != Success {
+					t.Errorf("add %s: %d %v", alias, status, err)
+					return
+				}
+				if i%2 == 0 {
+					if status, err := manager.DelAlias(alias); status != Success {
+						t.Errorf("del %s: %d %v", alias, status, err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		aliases, _, _ := manager.GetAlias(w%6 + 1)
+		for i := 0; i < perWorker; i++ {
+			alias := fmt.Sprintf("alias-%d-%d", w, i)
+			found := false
+			for _, a := range aliases {
+				found = found || a == alias
+			}
+			if found != (i%2 != 0) {
+				t.Errorf("alias %s found=%v", alias, found)
+			}
+		}
+	}
+
+	// 落盘内容和内存一致
+	reloaded := &SDVXManager{}
+	if err := reloaded.LoadAliases(aliasesPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reloaded.GetAliases()) != len(*manager.GetAliases()) {
+		t.Fatal("saved aliases differ from memory")
+	}
+}
+
+func TestSDVXManagerRaceHammer(t *testing.T) {
+	manager, aliasesPath := newTestSDVXManager(t)
+	dbPath := filepath.Join("testdata", "music_db.xml")
+
+	const rounds = 30
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		_, _ = manager.AddAlias(2, fmt.Sprintf("hammer-%d", i))
+	})
+	run(func(i int) {
+		_, _ = manager.DelAlias(fmt.Sprintf("hammer-%d", i))
+	})
+	run(func(i int) {
+		_ = manager.SimpleMatch("晕")
+		_ = manager.Match("hyper", true, true)
+		_ = manager.MatchAlias("hammer", true, true)
+	})
+	run(func(i int) {
+		_, _, _ = manager.GetAlias(2)
+		for _, aliasList := range *manager.GetAliases() {
+			_ = len(aliasList)
+		}
+		for id := range *manager.GetAll() {
+			_, _ = manager.Get(id)
+		}
+	})
+	run(func(i int) {
+		if err := manager.LoadData(dbPath); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func(i int) {
+		if err := manager.LoadAliases(aliasesPath); err != nil {
+			t.Error(err)
+		}
+	})
+
+	wg.Wait()
+}
+
+func TestSDVXSimpleMatchTypo(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	for query, want := range map[string]int32{
+		"晕舡":        2,
+		"Hyper Nva": 3,
+	} {
+		matches := manager.SimpleMatchScored(query)
+		if len(matches) == 0 || matches[0].Id != want {
+			t.Errorf("%s: expected %d, got %+v", query, want, matches)
+			continue
+		}
+		if matches[0].Score <= 0 || matches[0].Score >= 1 {
+			t.Errorf("%s: unexpected score %v", query, matches[0].Score)
+		}
+	}
+
+	if matches := manager.SimpleMatchScored("晕船"); len(matches) != 1 || matches[0].Score != 1 {
+		t.Errorf("exact alias should score 1, got %+v", matches)
+	}
+}
+
+func BenchmarkSDVXSimpleMatch(b *testing.B) {
+	manager, _ := newTestSDVXManager(b)
+
+	for _, query := range []string{"晕船", "hyper", "晕舡"} {
+		b.Run(query, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				manager.SimpleMatch(query)
+			}
+		})
+	}
+}
+
+func TestSDVXNormalizedMatch(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	if ids := manager.Match("hyper nova", true, false); len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("expected normalized title match, got %v", ids)
+	}
+	if ids := manager.Match("ｲｶｻﾏ", true, true); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("half-width katakana should match hiragana title, got %v", ids)
+	}
+	if hits := manager.MatchAlias("ＩＫＡＳＡＭＡ", true, false); len(hits) != 1 || hits[0].Id != 2 {
+		t.Errorf("expected full-width alias match, got %v", hits)
+	}
+
+	if status, _ := manager.AddAlias(3, "Ｉｋａｓａｍａ"); status != AliasAlreadyExists {
+		t.Errorf("normalized duplicate alias should be rejected, got %d", status)
+	}
+}
+
+func TestSDVXPinyinMatch(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	for _, query := range []string{"yunchuan", "YC", "yun chuan", "yunch"} {
+		if ids := manager.SimpleMatch(query); len(ids) != 1 || ids[0] != 2 {
+			t.Errorf("%s: expected [2], got %v", query, ids)
+		}
+	}
+}
+
+func TestSDVXTraditionalChinese(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	if ids := manager.SimpleMatch("暈船"); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("traditional query should find simplified alias, got %v", ids)
+	}
+	if status, _ := manager.AddAlias(3, "暈船"); status != AliasAlreadyExists {
+		t.Errorf("traditional variant should be a duplicate alias, got %d", status)
+	}
+}
+
+func TestSDVXRomajiMatch(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	for query, want := range map[string]struct {
+		id    int32
+		field string
+	}{
+		"kamisama":          {4, fieldYomigana},
+		"itazura":           {4, fieldYomigana},
+		"カミサマ":              {4, fieldYomigana},
+		"haipanova":         {3, fieldYomigana},
+		"seiten bon voyage": {6, fieldAscii},
+	} {
+		matches := manager.SimpleMatchScored(query)
+		if len(matches) != 1 || matches[0].Id != want.id || matches[0].Field != want.field {
+			t.Errorf("%s: expected %d via %s, got %+v", query, want.id, want.field, matches)
+		}
+	}
+
+	// 别名优先于罗马音
+	if matches := manager.SimpleMatchScored("ikasama"); len(matches) != 1 || matches[0].Id != 2 || matches[0].Field != fieldAlias {
+		t.Errorf("ikasama: expected alias of 2, got %+v", matches)
+	}
+}
+
+func TestSDVXRegistryNewVersions(t *testing.T) {
+	manager := &SDVXManager{}
+	if err := manager.LoadData(filepath.Join("testdata", "music_db_new.xml")); err != nil {
+		t.Fatal(err)
+	}
+
+	nabla, _ := manager.Get(10)
+	if nabla.Version != "∇" || nabla.DiffVer4 != "∇" || nabla.DifficultyList[3] != "nbl" || nabla.Difficulties["nbl"].Level != 19 {
+		t.Errorf("unexpected version 7 song %+v", nabla)
+	}
+
+	// 不认识的版本用占位名, 第4难度退回inf
+	future, _ := manager.Get(11)
+	if future.Version != "unknown(9)" || future.DifficultyList[3] != "inf" {
+		t.Errorf("unexpected unknown version song %+v", future)
+	}
+
+	// 配置中新增版本不需要改代码
+	registry, err := newSDVXRegistry(map[string]string{"9": "Future"}, map[string]string{"9": "FTR"}, map[string]string{"ftr": "infinite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.registry = registry
+	if err := manager.LoadData(filepath.Join("testdata", "music_db_new.xml")); err != nil {
+		t.Fatal(err)
+	}
+	future, _ = manager.Get(11)
+	if future.Version != "Future" || future.DiffVer4 != "Future" || future.Difficulties["ftr"].Level != 19 {
+		t.Errorf("unexpected configured version song %+v", future)
+	}
+
+	if _, err := newSDVXRegistry(map[string]string{"256": "bad"}, nil, nil); err == nil {
+		t.Error("expected invalid version error")
+	}
+}
+
+func TestSDVXLoadReport(t *testing.T) {
+	manager := &SDVXManager{}
+	if err := manager.LoadData(filepath.Join("testdata", "music_db_bad.xml")); err != nil {
+		t.Fatal(err)
+	}
+
+	report := manager.LoadReport()
+	if report.Loaded != 1 || report.Skipped != 4 || report.Error != "" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	problems := make(map[string]bool)
+	for _, warning := range report.Warnings {
+		problems[fmt.Sprintf("%d %s", warning.Id, warning.Field)] = true
+	}
+	for _, want := range []string{
+		"20 info.version",
+		"20 difficulty.exhaust.difnum",
+		"20 difficulty.infinite.price",
+		"20 difficulty.novice.radar",
+		"0 id",
+		"21 info",
+		"22 info.title_name",
+		"20 id",
+	} {
+		if !problems[want] {
+			t.Errorf("missing warning %q in %+v", want, report.Warnings)
+		}
+	}
+
+	music, _ := manager.Get(20)
+	if music.TitleName != "Good Song" || music.Version != "" || len(music.DifficultyList) != 2 || music.DifficultyList[1] != "xcd" {
+		t.Errorf("unexpected tolerant parse %+v", music)
+	}
+
+	// 整体失败时报告带错误, 旧曲库继续使用
+	if err := manager.LoadData(filepath.Join("testdata", "not_exist.xml")); err == nil {
+		t.Fatal("expected load error")
+	}
+	if report := manager.LoadReport(); report.Error == "" {
+		t.Error("expected error in report")
+	}
+	if exist, _ := manager.Exist(20); !exist {
+		t.Error("old catalog should keep serving")
+	}
+}
+
+// writeBenchMusicDB 把testdata中的曲目复制n份写入临时music_db(Shift-JIS), 接近真实曲库的大小
+func writeBenchMusicDB(b *testing.B, n int) string {
+	b.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "music_db.xml"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	musics := regexp.MustCompile(`(?s)<music id="\d+">.*?</music>`).FindAll(data, -1)
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="shift_jis"?>` + "\n<mdb>\n")
+	for i := 0; i < n; i++ {
+		music := musics[i%len(musics)]
+		buf.Write(regexp.MustCompile(`^<music id="\d+">`).ReplaceAll(music, []byte(fmt.Sprintf(`<music id="%d">`, i+1))))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("</mdb>\n")
+
+	path := filepath.Join(b.TempDir(), "music_db.xml")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkSDVXLoadData 2000首曲目的music_db加载耗时和内存
+// go test ./pkg/finder -run '^$' -bench BenchmarkSDVXLoadData -benchmem
+func BenchmarkSDVXLoadData(b *testing.B) {
+	path := writeBenchMusicDB(b, 2000)
+	manager := &SDVXManager{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := manager.LoadData(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecodeMusicDBWithoutDeclaration(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "music_db.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = data[bytes.IndexByte(data, '\n')+1:]
+
+	titles := make([]string, 0)
+	err = decodeMusicDB(bytes.NewReader(data), func(music *mdbMusic) {
+		titles = append(titles, *music.Info.TitleName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(titles) != 6 || titles[1] != "いかさまライフゲイム" {
+		t.Errorf("undeclared file should be read as Shift-JIS, got %q", titles)
+	}
+
+	if err := decodeMusicDB(strings.NewReader(`<?xml version="1.0"?><songs></songs>`), func(*mdbMusic) {}); err == nil {
+		t.Error("expected missing <mdb> error")
+	}
+}
+
+func TestSDVXCharFixup(t *testing.T) {
+	db := `<?xml version="1.0" encoding="utf-8"?>
+<mdb>
+  <music id="30">
+    <info>
+      <title_name>Caf齷 驩verdrive 齲</title_name>
+      <title_yomigana>カフェ</title_yomigana>
+      <artist_name>隍ber</artist_name>
+      <artist_yomigana>ウーバー</artist_yomigana>
+      <version>6</version>
+    </info>
+  </music>
+  <music id="31">
+    <info>
+      <title_name>Plain</title_name>
+      <version>6</version>
+    </info>
+  </music>
+</mdb>
+`
+	path := filepath.Join(t.TempDir(), "music_db.xml")
+	if err := os.WriteFile(path, []byte(db), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &SDVXManager{}
+	if err := manager.LoadData(path); err != nil {
+		t.Fatal(err)
+	}
+
+	music, _ := manager.Get(30)
+	if music.TitleName != "Café Øverdrive ♥" || music.ArtistName != "Über" {
+		t.Errorf("unexpected fixup %q / %q", music.TitleName, music.ArtistName)
+	}
+	if music.Raw == nil || music.Raw.TitleName != "Caf齷 驩verdrive 齲" || music.Raw.ArtistName != "隍ber" {
+		t.Errorf("raw text not kept: %+v", music.Raw)
+	}
+	if plain, _ := manager.Get(31); plain.Raw != nil {
+		t.Errorf("raw should be empty without fixup: %+v", plain.Raw)
+	}
+	if matches := manager.SimpleMatch("café"); len(matches) == 0 || matches[0] != 30 {
+		t.Errorf("fixed title should be searchable, got %+v", matches)
+	}
+
+	// 配置覆盖内置表, 替换为空时去掉该项
+	manager = &SDVXManager{fixup: newSDVXFixup(map[string]string{"齷": "e", "驩": ""})}
+	if err := manager.LoadData(path); err != nil {
+		t.Fatal(err)
+	}
+	if music, _ := manager.Get(30); music.TitleName != "Cafe 驩verdrive ♥" {
+		t.Errorf("unexpected overridden fixup %q", music.TitleName)
+	}
+}
+
+func TestSDVXOverlayMusicDB(t *testing.T) {
+	overlay := `<?xml version="1.0" encoding="utf-8"?>
+<mdb>
+  <music id="3">
+    <info>
+      <title_name>HYPERNOVA (omni)</title_name>
+      <version>6</version>
+    </info>
+  </music>
+  <music id="100">
+    <info>
+      <title_name>Custom Chart</title_name>
+      <version>6</version>
+    </info>
+  </music>
+</mdb>
+`
+	base := filepath.Join("testdata", "music_db.xml")
+	omni := filepath.Join(t.TempDir(), "music_db_omni.xml")
+	if err := os.WriteFile(omni, []byte(overlay), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := &SDVXManager{}
+	if err := manager.LoadData(base, omni); err != nil {
+		t.Fatal(err)
+	}
+
+	if music, _ := manager.Get(3); music.TitleName != "HYPERNOVA (omni)" || music.Source != omni {
+		t.Errorf("overlay should override base, got %q from %q", music.TitleName, music.Source)
+	}
+	if music, _ := manager.Get(1); music.Source != base {
+		t.Errorf("unexpected source %q", music.Source)
+	}
+	if music, _ := manager.Get(100); music == nil {
+		t.Error("overlay should add new songs")
+	}
+
+	conflicts := manager.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Id != 3 || conflicts[0].Source != omni ||
+		len(conflicts[0].Sources) != 2 || conflicts[0].Sources[0] != base {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+	if report := manager.LoadReport(); report.Loaded != 7 || len(report.Files) != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	// keep: 保留先出现的, 后面的文件只补充新曲目
+	manager = &SDVXManager{precedence: SDVXPrecedenceKeep}
+	if err := manager.LoadData(base, omni); err != nil {
+		t.Fatal(err)
+	}
+	if music, _ := manager.Get(3); music.TitleName != "HYPERNOVA" || music.Source != base {
+		t.Errorf("keep should prefer base, got %q from %q", music.TitleName, music.Source)
+	}
+	if conflicts := manager.Conflicts(); len(conflicts) != 1 || conflicts[0].Source != base {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+
+	// 任一文件失败时整体失败, 继续使用旧曲库
+	if err := manager.LoadData(base, filepath.Join("testdata", "not_exist.xml")); err == nil {
+		t.Fatal("expected load error")
+	}
+	if music, _ := manager.Get(100); music == nil {
+		t.Error("old catalog should be kept")
+	}
+
+	if _, err := parseSDVXPrecedence("newest"); err == nil {
+		t.Error("expected invalid precedence error")
+	}
+}
+
+func TestDecodeMusicDBCharset(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "music_db.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := data[bytes.IndexByte(data, '\n')+1:]
+
+	utf8 := "<mdb><music id=\"2\"><info><title_name>いかさまライフゲイム</title_name></info></music></mdb>"
+
+	for name, input := range map[string][]byte{
+		// 声明前有空白时仍按声明的Shift-JIS只解码一次
+		"leading whitespace": append([]byte("\r\n  "), data...),
+		// 有声明但没有encoding时和没有声明一样按Shift-JIS
+		"declaration without encoding": append([]byte(`<?xml version="1.0"?>`+"\n"), body...),
+		// 没有声明时按Shift-JIS
+		"no declaration": body,
+		// BOM表示UTF-8
+		"utf-8 bom":                     []byte("\xef\xbb\xbf<?xml version=\"1.0\" encoding=\"utf-8\"?>\n" + utf8),
+		"utf-8 bom without declaration": []byte("\xef\xbb\xbf" + utf8),
+	} {
+		titles := make(map[string]string)
+		err := decodeMusicDB(bytes.NewReader(input), func(music *mdbMusic) {
+			titles[music.Id] = *music.Info.TitleName
+		})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if titles["2"] != "いかさまライフゲイム" {
+			t.Errorf("%s: unexpected title %q", name, titles["2"])
+		}
+	}
+}
+
+// failingAliasStore 写入总是失败的别名存储
+type failingAliasStore struct {
+	aliases map[string][]string
+}
+
+func (s failingAliasStore) LoadAliases() (map[string][]string, error) {
+	return s.aliases, nil
+}
+
+func (s failingAliasStore) SaveAlias(map[string][]string, string, string) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestSDVXAliasSaveFailureKeepsSnapshot(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+	if err := manager.LoadAliasStore(failingAliasStore{aliases: map[string][]string{"1": {"old"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manager.AddAlias(1, "new"); err == nil {
+		t.Fatal("expected save error")
+	}
+	if _, err := manager.DelAlias("old"); err == nil {
+		t.Fatal("expected save error")
+	}
+
+	// 保存失败时内存中的别名不变
+	aliases, _, _ := manager.GetAlias(1)
+	if len(aliases) != 1 || aliases[0] != "old" {
+		t.Errorf("failed save should not change aliases, got %v", aliases)
+	}
+}
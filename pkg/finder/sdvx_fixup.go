@@ -0,0 +1,85 @@
+package finder
+
+import (
+	"sort"
+	"strings"
+)
+
+// SDVXCharFixup music_db中替代字 -> 实际字符
+// Shift-JIS里没有的字符(Ü/é/♥/♠等)在music_db中用生僻汉字代替, 这里是社区整理的对照表
+var SDVXCharFixup = map[string]string{
+	"驩": "Ø", "齲": "♥", "齶": "♡", "趁": "Ǣ", "騫": "á",
+	"曦": "à", "驫": "ā", "齷": "é", "骭": "ü", "隍": "Ü",
+	"雋": "Ǜ", "鬻": "♃", "鬥": "Ã", "鬆": "Ý", "鬮": "¡",
+	"龕": "€", "蹙": "ℱ", "頽": "ä", "黻": "*", "疉": "Ö",
+	"瀑": "À", "鑒": "₩", "盥": "⚙", "闃": "Ā", "霻": "♠",
+	"鑷": "ゔ", "罇": "ê", "曩": "è", "彜": "ū", "餮": "Ƶ",
+	"煢": "ø", "躔": "★", "齪": "♣", "釁": "🍄", "皹": "♪",
+	"蔕": "ῦ", "靃": "Ⅲ", "鑈": "♦",
+}
+
+// SDVXRawText 替换前的原始文本, 只在有替换时记录
+type SDVXRawText struct {
+	TitleName      string `json:"title_name"`
+	TitleYomigana  string `json:"title_yomigana"`
+	ArtistName     string `json:"artist_name"`
+	ArtistYomigana string `json:"artist_yomigana"`
+}
+
+// newSDVXFixup 内置对照表加上配置中的项, 配置的替换为空时去掉该项
+// 长的替代字优先
+func newSDVXFixup(overrides map[string]string) *strings.Replacer {
+	table := make(map[string]string, len(SDVXCharFixup)+len(overrides))
+	for from, to := range SDVXCharFixup {
+		table[from] = to
+	}
+	for from, to := range overrides {
+		if to == "" {
+			delete(table, from)
+			continue
+		}
+		table[from] = to
+	}
+
+	keys := make([]string, 0, len(table))
+	for from := range table {
+		if from != "" {
+			keys = append(keys, from)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	pairs := make([]string, 0, len(keys)*2)
+	for _, from := range keys {
+		pairs = append(pairs, from, table[from])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// defaultSDVXFixup 只有内置对照表的替换
+var defaultSDVXFixup = newSDVXFixup(nil)
+
+// fixup 替换曲名/曲师/读音中的替代字, 有替换时保留原始文本
+func fixup(replacer *strings.Replacer, info *SDVXMusicInfo) {
+	raw := SDVXRawText{
+		TitleName:      info.TitleName,
+		TitleYomigana:  info.TitleYomigana,
+		ArtistName:     info.ArtistName,
+		ArtistYomigana: info.ArtistYomigana,
+	}
+
+	info.TitleName = replacer.Replace(info.TitleName)
+	info.TitleYomigana = replacer.Replace(info.TitleYomigana)
+	info.ArtistName = replacer.Replace(info.ArtistName)
+	info.ArtistYomigana = replacer.Replace(info.ArtistYomigana)
+
+	if raw.TitleName != info.TitleName || raw.TitleYomigana != info.TitleYomigana ||
+		raw.ArtistName != info.ArtistName || raw.ArtistYomigana != info.ArtistYomigana {
+		info.Raw = &raw
+	}
+}
@@ -0,0 +1,371 @@
+package finder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// SDVXLoadWarning 加载music_db时发现的问题
+type SDVXLoadWarning struct {
+	File    string `json:"file"`    // 所在的数据库文件
+	Id      int32  `json:"id"`      // 曲目id(解析不到时为0)
+	Field   string `json:"field"`   // 字段路径, 如 info.version / difficulty.exhaust.difnum
+	Problem string `json:"problem"` // 问题和处理方式
+}
+
+// SDVXLoadConflict 多个数据库文件中出现的同一个id
+type SDVXLoadConflict struct {
+	Id      int32    `json:"id"`
+	Title   string   `json:"title"`   // 采用的曲名
+	Sources []string `json:"sources"` // 出现该id的文件(按加载顺序)
+	Source  string   `json:"source"`  // 采用的文件
+}
+
+// SDVXLoadReport 最近一次加载music_db的报告
+type SDVXLoadReport struct {
+	Files      []string           `json:"files"` // 按顺序加载的数据库文件
+	Time       time.Time          `json:"time"`
+	Precedence string             `json:"precedence"` // 同一id的取舍规则
+	Loaded     int                `json:"loaded"`     // 加载的曲目数(合并后)
+	Skipped    int                `json:"skipped"`    // 跳过的曲目数
+	Error      string             `json:"error"`      // 整体失败的原因(此时继续使用旧曲库)
+	Conflicts  []SDVXLoadConflict `json:"conflicts"`  // 多个文件中出现的id
+	Warnings   []SDVXLoadWarning  `json:"warnings"`   // 跳过或取默认值的字段
+}
+
+// 多个数据库文件中出现同一个id时的取舍规则
+const (
+	SDVXPrecedenceOverride = "override" // 后面的文件覆盖前面的(默认, 基础库 + omnimix/自制谱)
+	SDVXPrecedenceKeep     = "keep"     // 保留先出现的, 后面的文件只补充新曲目
+)
+
+// parseSDVXPrecedence 校验取舍规则, 为空时使用override
+func parseSDVXPrecedence(precedence string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(precedence)) {
+	case "", SDVXPrecedenceOverride:
+		return SDVXPrecedenceOverride, nil
+	case SDVXPrecedenceKeep:
+		return SDVXPrecedenceKeep, nil
+	default:
+		return "", fmt.Errorf("invalid sdvx db precedence: %q", precedence)
+	}
+}
+
+// mdbMusic music_db中的<music>
+// 字段都是原始文本, 元素不存在时为nil, 由sdvxParser转换和校验
+type mdbMusic struct {
+	Id   string `xml:"id,attr"`
+	Info *struct {
+		TitleName        *string `xml:"title_name"`
+		TitleYomigana    *string `xml:"title_yomigana"`
+		ArtistName       *string `xml:"artist_name"`
+		ArtistYomigana   *string `xml:"artist_yomigana"`
+		Ascii            *string `xml:"ascii"`
+		BPMMax           *string `xml:"bpm_max"`
+		BPMMin           *string `xml:"bpm_min"`
+		DistributionDate *string `xml:"distribution_date"`
+		Volume           *string `xml:"volume"`
+		BGNo             *string `xml:"bg_no"`
+		Genre            *string `xml:"genre"`
+		IsFixed          *string `xml:"is_fixed"`
+		Version          *string `xml:"version"`
+		DemoPri          *string `xml:"demo_pri"`
+		InfVer           *string `xml:"inf_ver"`
+	} `xml:"info"`
+	Difficulty *struct {
+		Charts []mdbChart `xml:",any"` // 难度标签由配置决定, 按标签名匹配
+	} `xml:"difficulty"`
+}
+
+// mdbChart <difficulty>下的一个难度
+type mdbChart struct {
+	XMLName     xml.Name
+	Difnum      *string `xml:"difnum"`
+	Illustrator *string `xml:"illustrator"`
+	EffectedBy  *string `xml:"effected_by"`
+	Price       *string `xml:"price"`
+	Limited     *string `xml:"limited"`
+	JacketPrint *string `xml:"jacket_print"`
+	JacketMask  *string `xml:"jacket_mask"`
+	MaxExscore  *string `xml:"max_exscore"`
+	Radar       *struct {
+		Notes    *string `xml:"notes"`
+		Peak     *string `xml:"peak"`
+		Tsumami  *string `xml:"tsumami"`
+		Tricky   *string `xml:"tricky"`
+		HandTrip *string `xml:"hand-trip"`
+		OneHand  *string `xml:"one-hand"`
+	} `xml:"radar"`
+}
+
+// charsetReader 按编码名解码为UTF-8, music_db为Shift-JIS
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "shift_jis", "shift-jis", "sjis", "cp932", "windows-31j", "ms_kanji":
+		return transform.NewReader(input, japanese.ShiftJIS.NewDecoder()), nil
+	case "utf-8", "utf8":
+		return input, nil
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+}
+
+// musicDBInput 处理encoding/xml不认识的两种开头, 其余按XML声明中的encoding交给CharsetReader
+// 有UTF-8 BOM时去掉BOM按UTF-8; 没有XML声明或声明中没有encoding时按Shift-JIS
+func musicDBInput(r io.Reader) io.Reader {
+	reader := bufio.NewReader(r)
+
+	bom := []byte("\xef\xbb\xbf")
+	if head, _ := reader.Peek(len(bom)); bytes.Equal(head, bom) {
+		_, _ = reader.Discard(len(bom))
+		return reader
+	}
+
+	head, _ := reader.Peek(256)
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if bytes.HasPrefix(head, []byte("<?xml")) {
+		if end := bytes.Index(head, []byte("?>")); end >= 0 && bytes.Contains(head[:end], []byte("encoding")) {
+			return reader
+		}
+	}
+	return transform.NewReader(reader, japanese.ShiftJIS.NewDecoder())
+}
+
+// decodeMusicDB 流式解析music_db, 每解析完一个<music>调用一次fn
+// Shift-JIS由CharsetReader按XML声明转为UTF-8
+func decodeMusicDB(r io.Reader, fn func(music *mdbMusic)) error {
+	decoder := xml.NewDecoder(musicDBInput(r))
+	decoder.CharsetReader = charsetReader
+
+	root := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !root {
+			if start.Name.Local != "mdb" {
+				return fmt.Errorf("sdvx db has no <mdb> element")
+			}
+			root = true
+			continue
+		}
+
+		if start.Name.Local != "music" {
+			if err := decoder.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var music mdbMusic
+		if err := decoder.DecodeElement(&music, &start); err != nil {
+			return err
+		}
+		fn(&music)
+	}
+
+	if !root {
+		return fmt.Errorf("sdvx db has no <mdb> element")
+	}
+	return nil
+}
+
+// sdvxParser 把<music>转换为曲目信息, 有问题的字段取默认值, 缺少必要字段的曲目跳过
+// 所有问题都记录到warnings
+type sdvxParser struct {
+	registry *sdvxRegistry
+	fixup    *strings.Replacer
+	file     string
+	id       int32
+	warnings []SDVXLoadWarning
+}
+
+// warn 记录一个问题
+func (p *sdvxParser) warn(field, format string, v ...any) {
+	p.warnings = append(p.warnings, SDVXLoadWarning{File: p.file, Id: p.id, Field: field, Problem: fmt.Sprintf(format, v...)})
+}
+
+// str 字符串字段, 缺失时为空
+func (p *sdvxParser) str(field string, text *string) string {
+	if text == nil {
+		p.warn(field, "missing, default empty")
+		return ""
+	}
+	return *text
+}
+
+// uint 无符号整数字段, 缺失或不合法时为0
+func (p *sdvxParser) uint(field string, text *string, bits int) uint64 {
+	if text == nil {
+		p.warn(field, "missing, default 0")
+		return 0
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(*text), 10, bits)
+	if err != nil {
+		p.warn(field, "invalid u%d %q, default 0", bits, *text)
+		return 0
+	}
+	return value
+}
+
+// int 有符号整数字段, 缺失或不合法时为0
+func (p *sdvxParser) int(field string, text *string, bits int) int64 {
+	if text == nil {
+		p.warn(field, "missing, default 0")
+		return 0
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(*text), 10, bits)
+	if err != nil {
+		p.warn(field, "invalid s%d %q, default 0", bits, *text)
+		return 0
+	}
+	return value
+}
+
+// music 转换一个<music>, 返回false表示跳过
+func (p *sdvxParser) music(music *mdbMusic) (SDVXMusicInfo, bool) {
+	var info SDVXMusicInfo
+	p.id = 0
+
+	id, err := strconv.ParseInt(strings.TrimSpace(music.Id), 10, 32)
+	if err != nil {
+		p.warn("id", "invalid id %q, skipped", music.Id)
+		return info, false
+	}
+	p.id = int32(id)
+	info.Id = p.id
+
+	musicInfo := music.Info
+	if musicInfo == nil {
+		p.warn("info", "missing, skipped")
+		return info, false
+	}
+
+	info.TitleName = p.str("info.title_name", musicInfo.TitleName)
+	if strings.TrimSpace(info.TitleName) == "" {
+		p.warn("info.title_name", "empty title, skipped")
+		return info, false
+	}
+	info.TitleYomigana = p.str("info.title_yomigana", musicInfo.TitleYomigana)
+	info.Ascii = p.str("info.ascii", musicInfo.Ascii)
+	info.ArtistName = p.str("info.artist_name", musicInfo.ArtistName)
+	info.ArtistYomigana = p.str("info.artist_yomigana", musicInfo.ArtistYomigana)
+	fixup(p.fixup, &info)
+
+	// 第四难度版本
+	infVer := uint8(p.uint("info.inf_ver", musicInfo.InfVer, 8))
+
+	info.DiffVer4 = p.registry.versionName(infVer)
+	info.Version = p.registry.versionName(uint8(p.uint("info.version", musicInfo.Version, 8)))
+	info.Volume = uint16(p.uint("info.volume", musicInfo.Volume, 16))
+	info.IsFixed = p.uint("info.is_fixed", musicInfo.IsFixed, 8) != 0
+	info.Genre = uint32(p.uint("info.genre", musicInfo.Genre, 32))
+	info.DistributionDate = uint32(p.uint("info.distribution_date", musicInfo.DistributionDate, 32))
+	info.DemoPri = int8(p.int("info.demo_pri", musicInfo.DemoPri, 8))
+	info.BPMMax = float32(p.uint("info.bpm_max", musicInfo.BPMMax, 32)) / 100.0
+	info.BPMMin = float32(p.uint("info.bpm_min", musicInfo.BPMMin, 32)) / 100.0
+	info.BGNo = uint16(p.uint("info.bg_no", musicInfo.BGNo, 16))
+
+	// 难度列表
+	info.Difficulties = make(map[string]DifficultyInfo)
+	info.DifficultyList = make([]string, 0)
+
+	if music.Difficulty == nil {
+		p.warn("difficulty", "missing, no charts")
+		return info, true
+	}
+
+	charts := make(map[string]*mdbChart, len(music.Difficulty.Charts))
+	for i := range music.Difficulty.Charts {
+		chart := &music.Difficulty.Charts[i]
+		charts[chart.XMLName.Local] = chart
+	}
+
+	for _, slot := range sdvxSlots {
+		// 第4难度按版本换成对应简写
+		if slot == "inf" {
+			slot = p.registry.infiniteName(infVer)
+		}
+		tag := p.registry.levelTag(slot)
+		chart, exist := charts[tag]
+		if !exist {
+			continue
+		}
+
+		difficulty, ok := p.difficulty("difficulty."+tag, chart)
+		if !ok {
+			continue
+		}
+
+		info.Difficulties[slot] = difficulty
+		info.DifficultyList = append(info.DifficultyList, slot)
+	}
+
+	return info, true
+}
+
+// difficulty 转换一个难度, 等级不合法时跳过该难度
+func (p *sdvxParser) difficulty(path string, chart *mdbChart) (DifficultyInfo, bool) {
+	var info DifficultyInfo
+
+	if chart.Difnum == nil {
+		p.warn(path+".difnum", "missing level, chart skipped")
+		return info, false
+	}
+	difnum, err := strconv.ParseUint(strings.TrimSpace(*chart.Difnum), 10, 8)
+	if err != nil {
+		p.warn(path+".difnum", "invalid level %q, chart skipped", *chart.Difnum)
+		return info, false
+	}
+
+	info.Level = uint8(difnum)
+	info.JacketMask = int32(p.int(path+".jacket_mask", chart.JacketMask, 32))
+	info.JacketPrint = int32(p.int(path+".jacket_print", chart.JacketPrint, 32))
+	info.Limited = uint8(p.uint(path+".limited", chart.Limited, 8))
+	info.Price = int32(p.int(path+".price", chart.Price, 32))
+	info.EffectedBy = p.str(path+".effected_by", chart.EffectedBy)
+	info.Illustrator = p.str(path+".illustrator", chart.Illustrator)
+
+	info.MaxExscore = 0
+	info.Radar = NewRadarInfo()
+
+	// 旧谱面没有最大得分和六维
+	if chart.MaxExscore != nil {
+		info.MaxExscore = int32(p.int(path+".max_exscore", chart.MaxExscore, 32))
+
+		radar := chart.Radar
+		if radar == nil {
+			p.warn(path+".radar", "missing, default 0")
+			return info, true
+		}
+
+		path += ".radar"
+		info.Radar.HandTrip = uint8(p.uint(path+".hand-trip", radar.HandTrip, 8))
+		info.Radar.OneHand = uint8(p.uint(path+".one-hand", radar.OneHand, 8))
+		info.Radar.Notes = uint8(p.uint(path+".notes", radar.Notes, 8))
+		info.Radar.Peak = uint8(p.uint(path+".peak", radar.Peak, 8))
+		info.Radar.Tricky = uint8(p.uint(path+".tricky", radar.Tricky, 8))
+		info.Radar.Tsumami = uint8(p.uint(path+".tsumami", radar.Tsumami, 8))
+	}
+
+	return info, true
+}
@@ -0,0 +1,119 @@
+package finder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 随机抽谱面时的权重
+const (
+	SDVXWeightChart = "chart" // 每个谱面相同(默认)
+	SDVXWeightSong  = "song"  // 每首曲目相同, 不受曲目满足条件的谱面数影响
+	SDVXWeightLevel = "level" // 按等级, 等级越高越容易抽到
+)
+
+// sdvxDailySeed 每日种子, 同一天同样的条件抽到的谱面相同
+const sdvxDailySeed = "daily"
+
+// sdvxSeed 把种子换算为随机数生成器
+// 为空时随机生成一个, daily为当天日期, 返回实际使用的种子以便复现
+func sdvxSeed(seed string, now time.Time) (string, *rand.Rand) {
+	switch strings.TrimSpace(seed) {
+	case "":
+		seed = strconv.FormatUint(rand.Uint64(), 36)
+	case sdvxDailySeed:
+		seed = sdvxDailySeed + "-" + now.Format("2006-01-02")
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(seed))
+	sum := hash.Sum64()
+	return seed, rand.New(rand.NewPCG(sum, sum>>32))
+}
+
+// sdvxChartWeights 满足条件的谱面的权重
+func sdvxChartWeights(charts []SDVXChart, weight string) ([]float64, error) {
+	weights := make([]float64, len(charts))
+	switch strings.ToLower(strings.TrimSpace(weight)) {
+	case "", SDVXWeightChart:
+		for i := range charts {
+			weights[i] = 1
+		}
+	case SDVXWeightSong:
+		songCharts := make(map[int32]int)
+		for i := range charts {
+			songCharts[charts[i].Id]++
+		}
+		for i := range charts {
+			weights[i] = 1 / float64(songCharts[charts[i].Id])
+		}
+	case SDVXWeightLevel:
+		for i := range charts {
+			weights[i] = float64(charts[i].Level)
+		}
+	default:
+		return nil, fmt.Errorf("unknown weight: %s", weight)
+	}
+	return weights, nil
+}
+
+// RandomCharts 从满足条件的谱面中按权重随机抽count个, 同一首曲目只抽一次
+// 种子相同且曲库不变时结果相同, 返回实际使用的种子
+func (manager *SDVXManager) RandomCharts(filter SDVXChartFilter, count int, weight, seed string) ([]SDVXChart, string, error) {
+	if err := filter.normalize(manager.sdvxRegistry()); err != nil {
+		return nil, "", err
+	}
+
+	candidates := make([]SDVXChart, 0)
+	for _, chart := range manager.charts() {
+		if filter.match(&chart) {
+			candidates = append(candidates, chart)
+		}
+	}
+
+	weights, err := sdvxChartWeights(candidates, weight)
+	if err != nil {
+		return nil, "", err
+	}
+
+	seed, random := sdvxSeed(seed, time.Now())
+
+	picked := make([]SDVXChart, 0, count)
+	for len(picked) < count {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+		if total <= 0 {
+			break
+		}
+
+		// 浮点误差时落到最后一个可抽的谱面
+		target := random.Float64() * total
+		chosen := -1
+		for i, w := range weights {
+			if w <= 0 {
+				continue
+			}
+			chosen = i
+			if target < w {
+				break
+			}
+			target -= w
+		}
+		picked = append(picked, candidates[chosen])
+
+		// 同一首曲目的其他谱面不再参与
+		for j := range candidates {
+			if candidates[j].Id == candidates[chosen].Id {
+				weights[j] = 0
+			}
+		}
+	}
+
+	return picked, seed, nil
+}
@@ -0,0 +1,64 @@
+package finder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSDVXRandomCharts(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	filter := SDVXChartFilter{MinLevel: 16}
+	first, seed, err := manager.RandomCharts(filter, 3, "", "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != "challenge" || len(first) != 3 {
+		t.Fatalf("unexpected draw %q %+v", seed, first)
+	}
+
+	// 同一首曲目只抽一次, 抽到的谱面都满足条件
+	songs := make(map[int32]bool)
+	for _, chart := range first {
+		if songs[chart.Id] || chart.Level < 16 {
+			t.Errorf("unexpected chart %+v in %+v", chart, first)
+		}
+		songs[chart.Id] = true
+	}
+
+	// 种子相同时结果相同, 没有种子时返回生成的种子用于复现
+	for _, weight := range []string{SDVXWeightChart, SDVXWeightSong, SDVXWeightLevel} {
+		a, _, _ := manager.RandomCharts(filter, 3, weight, "same")
+		b, _, _ := manager.RandomCharts(filter, 3, weight, "same")
+		for i := range a {
+			if a[i].Id != b[i].Id || a[i].Slot != b[i].Slot {
+				t.Errorf("%s: same seed should give same draw %+v %+v", weight, a, b)
+			}
+		}
+	}
+	_, generated, _ := manager.RandomCharts(filter, 1, "", "")
+	if generated == "" {
+		t.Error("expected generated seed")
+	}
+
+	// 满足条件的曲目不足时全部返回
+	if charts, _, _ := manager.RandomCharts(SDVXChartFilter{MinLevel: 19}, 10, "", "x"); len(charts) != 3 {
+		t.Errorf("expected all 3 songs with lv19, got %+v", charts)
+	}
+	if charts, _, _ := manager.RandomCharts(SDVXChartFilter{MinBPM: 150, MaxBPM: 180, Genre: 16}, 10, "", "x"); len(charts) != 3 {
+		t.Errorf("expected 3 songs in bpm 150~180, got %+v", charts)
+	}
+
+	if _, _, err := manager.RandomCharts(filter, 1, "heavy", ""); err == nil {
+		t.Error("expected unknown weight error")
+	}
+}
+
+func TestSDVXDailySeed(t *testing.T) {
+	day := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	seed, a := sdvxSeed("daily", day)
+	_, b := sdvxSeed("daily", day.Add(10*time.Hour))
+	if seed != "daily-2024-05-01" || a.Uint64() != b.Uint64() {
+		t.Errorf("daily seed should be stable within a day: %s", seed)
+	}
+}
@@ -0,0 +1,172 @@
+package finder
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sdvxRadarFields 六维的名称, 顺序同RadarInfo.vector
+var sdvxRadarFields = []string{"notes", "peak", "tsumami", "tricky", "hand_trip", "one_hand"}
+
+// sdvxRadarLevels 画像中high/mid/low对应的百分位(在候选谱面中)
+var sdvxRadarLevels = map[string]float64{"low": 0.1, "mid": 0.5, "high": 0.9}
+
+// vector 六维向量
+func (r RadarInfo) vector() [6]float64 {
+	return [6]float64{float64(r.Notes), float64(r.Peak), float64(r.Tsumami), float64(r.Tricky), float64(r.HandTrip), float64(r.OneHand)}
+}
+
+// radarField 六维名称在向量中的位置, 忽略大小写和-/_
+func radarField(name string) (int, bool) {
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+	for i, field := range sdvxRadarFields {
+		if strings.ReplaceAll(field, "_", "") == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SDVXSimilarChart 带六维距离的谱面
+type SDVXSimilarChart struct {
+	SDVXChart
+	Distance float64 `json:"distance"` // 六维向量的欧氏距离, 越小越相似
+}
+
+// hasRadar 旧谱面没有六维
+func (chart *SDVXChart) hasRadar() bool {
+	return chart.Radar != RadarInfo{}
+}
+
+// nearestCharts 满足条件且有六维的谱面中, 按指定维度与target的距离从近到远取count个
+// skip为true的谱面不参与
+func nearestCharts(charts []SDVXChart, filter *SDVXChartFilter, target [6]float64, dims []int, count int, skip func(*SDVXChart) bool) []SDVXSimilarChart {
+	result := make([]SDVXSimilarChart, 0)
+	for i := range charts {
+		chart := &charts[i]
+		if !chart.hasRadar() || !filter.match(chart) || (skip != nil && skip(chart)) {
+			continue
+		}
+
+		vector := chart.Radar.vector()
+		sum := 0.0
+		for _, dim := range dims {
+			sum += (vector[dim] - target[dim]) * (vector[dim] - target[dim])
+		}
+		result = append(result, SDVXSimilarChart{SDVXChart: *chart, Distance: math.Sqrt(sum)})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Distance < result[j].Distance })
+	if len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
+
+// SimilarCharts 与某首曲目的某个难度六维最接近的其他曲目的谱面
+// slot为空时取该曲目最高的有六维的难度, window大于0时只看等级相差window以内的谱面
+func (manager *SDVXManager) SimilarCharts(id int32, slot string, window int, filter SDVXChartFilter, count int) (*SDVXChart, []SDVXSimilarChart, error) {
+	registry := manager.sdvxRegistry()
+	if err := filter.normalize(registry); err != nil {
+		return nil, nil, err
+	}
+	slot = strings.ToLower(strings.TrimSpace(slot))
+	if abbr, ok := sdvxSlotAlias[slot]; ok {
+		slot = abbr
+	}
+
+	charts := manager.charts()
+
+	var source *SDVXChart
+	for i := range charts {
+		chart := &charts[i]
+		if chart.Id != id || !chart.hasRadar() {
+			continue
+		}
+		switch {
+		case slot == "":
+			if source == nil || sdvxSlotOrder(chart.Slot) > sdvxSlotOrder(source.Slot) {
+				source = chart
+			}
+		case chart.Slot == slot || (slot == "inf" && registry.isInfinite(chart.Slot)):
+			source = chart
+		}
+	}
+	if source == nil {
+		return nil, nil, fmt.Errorf("chart %d %s not found or has no radar", id, slot)
+	}
+
+	if window > 0 {
+		filter.MinLevel = uint8(max(int(source.Level)-window, 0))
+		filter.MaxLevel = uint8(min(int(source.Level)+window, math.MaxUint8))
+	}
+
+	similar := nearestCharts(charts, &filter, source.Radar.vector(), []int{0, 1, 2, 3, 4, 5}, count, func(chart *SDVXChart) bool {
+		return chart.Id == source.Id
+	})
+	return source, similar, nil
+}
+
+// ChartsByRadar 按六维画像找谱面, 只比较画像中给出的维度
+// 画像的值可以是数值, 也可以是high/mid/low(取满足条件的谱面中该维度的90/50/10百分位)
+// 返回换算后的画像
+func (manager *SDVXManager) ChartsByRadar(profile map[string]string, filter SDVXChartFilter, count int) (map[string]float64, []SDVXSimilarChart, error) {
+	if err := filter.normalize(manager.sdvxRegistry()); err != nil {
+		return nil, nil, err
+	}
+	if len(profile) == 0 {
+		return nil, nil, fmt.Errorf("empty radar profile")
+	}
+
+	charts := manager.charts()
+	candidates := make([]*SDVXChart, 0)
+	for i := range charts {
+		if charts[i].hasRadar() && filter.match(&charts[i]) {
+			candidates = append(candidates, &charts[i])
+		}
+	}
+
+	var target [6]float64
+	dims := make([]int, 0, len(profile))
+	resolved := make(map[string]float64, len(profile))
+	for name, value := range profile {
+		dim, ok := radarField(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown radar field: %s", name)
+		}
+
+		value = strings.ToLower(strings.TrimSpace(value))
+		if percentile, ok := sdvxRadarLevels[value]; ok {
+			target[dim] = radarPercentile(candidates, dim, percentile)
+		} else {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid radar value %s=%s", name, value)
+			}
+			target[dim] = number
+		}
+
+		dims = append(dims, dim)
+		resolved[sdvxRadarFields[dim]] = target[dim]
+	}
+
+	return resolved, nearestCharts(charts, &filter, target, dims, count, nil), nil
+}
+
+// radarPercentile 谱面某个维度的百分位, 没有谱面时为0
+func radarPercentile(charts []*SDVXChart, dim int, percentile float64) float64 {
+	if len(charts) == 0 {
+		return 0
+	}
+
+	values := make([]float64, 0, len(charts))
+	for _, chart := range charts {
+		values = append(values, chart.Radar.vector()[dim])
+	}
+	sort.Float64s(values)
+
+	return values[int(math.Round(percentile*float64(len(values)-1)))]
+}
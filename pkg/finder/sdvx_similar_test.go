@@ -0,0 +1,60 @@
+package finder
+
+import "testing"
+
+func TestSDVXSimilarCharts(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	source, charts, err := manager.SimilarCharts(2, "exh", 0, SDVXChartFilter{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Id != 2 || source.Slot != "exh" || len(charts) != 3 || charts[0].Id != 5 || charts[0].Slot != "exh" || charts[0].Distance != 20 {
+		t.Fatalf("unexpected similar charts %+v %+v", source, charts)
+	}
+	for _, chart := range charts {
+		if chart.Id == 2 {
+			t.Errorf("source song should be excluded: %+v", chart)
+		}
+	}
+
+	// 不指定难度时取最高的有六维的难度, window限制等级
+	source, charts, _ = manager.SimilarCharts(3, "", 1, SDVXChartFilter{}, 10)
+	if source.Slot != "mxm" {
+		t.Errorf("expected mxm as source, got %s", source.Slot)
+	}
+	for _, chart := range charts {
+		if chart.Level < 18 {
+			t.Errorf("chart out of level window: %+v", chart)
+		}
+	}
+
+	if _, _, err := manager.SimilarCharts(1, "", 0, SDVXChartFilter{}, 10); err == nil {
+		t.Error("expected error for chart without radar")
+	}
+}
+
+func TestSDVXChartsByRadar(t *testing.T) {
+	manager, _ := newTestSDVXManager(t)
+
+	profile, charts, err := manager.ChartsByRadar(map[string]string{"tsumami": "high", "Peak": "low"}, SDVXChartFilter{MinLevel: 17, MaxLevel: 18}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile["tsumami"] != 190 || profile["peak"] != 130 {
+		t.Errorf("unexpected resolved profile %v", profile)
+	}
+	if len(charts) != 2 || charts[0].Id != 3 || charts[0].Distance != 10 || charts[1].Id != 6 {
+		t.Errorf("unexpected charts %+v", charts)
+	}
+
+	if _, charts, _ = manager.ChartsByRadar(map[string]string{"hand-trip": "110"}, SDVXChartFilter{}, 1); len(charts) != 1 || charts[0].Radar.HandTrip != 110 {
+		t.Errorf("unexpected numeric profile result %+v", charts)
+	}
+
+	for _, profile := range []map[string]string{{}, {"speed": "high"}, {"peak": "very"}} {
+		if _, _, err := manager.ChartsByRadar(profile, SDVXChartFilter{}, 1); err == nil {
+			t.Errorf("expected error for %v", profile)
+		}
+	}
+}
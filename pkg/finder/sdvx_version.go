@@ -0,0 +1,146 @@
+package finder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var SDVXVersionName = []string{"", "Booth", "Infinite Infection", "Gravity Wars", "Heavenly Haven", "Vivid Wave", "Exceed Gear", "∇"}
+
+// SDVXInfiniteName 第4难度版本(inf_ver) -> 难度简写
+var SDVXInfiniteName = map[uint8]string{2: "inf", 3: "grv", 4: "hvn", 5: "vvd", 6: "xcd", 7: "nbl"}
+
+// SDVXLevelMapper 难度简写 -> music_db中的难度标签
+var SDVXLevelMapper = map[string]string{
+	"nov": "novice",
+	"adv": "advanced",
+	"exh": "exhaust",
+	"inf": "infinite",
+	"grv": "infinite",
+	"hvn": "infinite",
+	"vvd": "infinite",
+	"xcd": "infinite",
+	"nbl": "infinite",
+	"mxm": "maximum",
+	"ult": "ultimate",
+}
+
+// sdvxSlots 难度槽顺序, inf为第4难度(按inf_ver换成对应简写)
+var sdvxSlots = []string{"nov", "adv", "exh", "inf", "mxm", "ult"}
+
+// sdvxRegistry SDVX版本名、第4难度简写和难度标签
+// 配置中的项覆盖内置表, 新版本只需要改配置
+type sdvxRegistry struct {
+	versions map[uint8]string  // 版本号 -> 版本名
+	infinite map[uint8]string  // 第4难度版本 -> 难度简写
+	levels   map[string]string // 难度简写 -> music_db中的难度标签
+}
+
+// newSDVXRegistry 内置表加上配置中的项, 配置的key是版本号
+func newSDVXRegistry(versions, infinite, levels map[string]string) (*sdvxRegistry, error) {
+	r := &sdvxRegistry{
+		versions: make(map[uint8]string),
+		infinite: make(map[uint8]string),
+		levels:   make(map[string]string),
+	}
+
+	for version, name := range SDVXVersionName {
+		r.versions[uint8(version)] = name
+	}
+	for version, abbr := range SDVXInfiniteName {
+		r.infinite[version] = abbr
+	}
+	for abbr, tag := range SDVXLevelMapper {
+		r.levels[abbr] = tag
+	}
+
+	if err := parseSDVXVersions(versions, r.versions); err != nil {
+		return nil, err
+	}
+	if err := parseSDVXVersions(infinite, r.infinite); err != nil {
+		return nil, err
+	}
+	for abbr, tag := range levels {
+		r.levels[strings.ToLower(abbr)] = tag
+	}
+
+	return r, nil
+}
+
+// defaultSDVXRegistry 只有内置表的配置
+var defaultSDVXRegistry, _ = newSDVXRegistry(nil, nil, nil)
+
+// parseSDVXVersions 版本号字符串转数字后写入to
+func parseSDVXVersions(from map[string]string, to map[uint8]string) error {
+	for key, value := range from {
+		version, err := strconv.ParseUint(strings.TrimSpace(key), 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid sdvx version: %q", key)
+		}
+		to[uint8(version)] = value
+	}
+	return nil
+}
+
+// versionName 版本名, 不认识的版本返回占位名
+func (r *sdvxRegistry) versionName(version uint8) string {
+	if name, ok := r.versions[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", version)
+}
+
+// infiniteName 第4难度的简写, 不认识的版本退回inf
+func (r *sdvxRegistry) infiniteName(infVer uint8) string {
+	if abbr, ok := r.infinite[infVer]; ok {
+		return strings.ToLower(abbr)
+	}
+	return "inf"
+}
+
+// levelTag 难度简写对应的music_db标签, 不认识的第4难度简写退回infinite
+func (r *sdvxRegistry) levelTag(abbr string) string {
+	if tag, ok := r.levels[abbr]; ok {
+		return tag
+	}
+	return "infinite"
+}
+
+// parseVersion 版本号、版本名或版本名首字母(忽略大小写, 如 EG -> Exceed Gear)转换为版本名
+func (r *sdvxRegistry) parseVersion(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if version, err := strconv.ParseUint(s, 10, 8); err == nil {
+		return r.versionName(uint8(version)), true
+	}
+
+	for version := 1; version <= 255; version++ {
+		name, ok := r.versions[uint8(version)]
+		if ok && (strings.EqualFold(name, s) || strings.EqualFold(initials(name), s)) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// initials 每个单词的首字母
+func initials(name string) string {
+	var b strings.Builder
+	for _, word := range strings.Fields(name) {
+		b.WriteString(string([]rune(word)[:1]))
+	}
+	return b.String()
+}
+
+// isInfinite 是否为第4难度的简写
+func (r *sdvxRegistry) isInfinite(abbr string) bool {
+	if abbr == "inf" {
+		return true
+	}
+	for _, infinite := range r.infinite {
+		if strings.EqualFold(infinite, abbr) {
+			return true
+		}
+	}
+	return false
+}
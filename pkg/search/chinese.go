@@ -0,0 +1,50 @@
+package search
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"unicode/utf8"
+)
+
+// t2sData 繁体->简体单字对照表(随程序打包)
+//
+//go:embed data/t2s.txt
+var t2sData string
+
+// t2s 繁体 -> 简体
+var t2s = func() map[rune]rune {
+	table := make(map[rune]rune, 4200)
+
+	scanner := bufio.NewScanner(strings.NewReader(t2sData))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		from, to, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+
+		f, _ := utf8.DecodeRuneInString(from)
+		t, _ := utf8.DecodeRuneInString(to)
+		table[f] = t
+	}
+
+	return table
+}()
+
+// foldChinese 繁体字转简体字(逐字), 其余字符原样返回
+func foldChinese(r rune) rune {
+	if s, ok := t2s[r]; ok {
+		return s
+	}
+	return r
+}
+
+// ToSimplified 繁体转简体(逐字)
+func ToSimplified(s string) string {
+	return strings.Map(foldChinese, s)
+}
@@ -0,0 +1,298 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Hit 命中的索引条目
+type Hit struct {
+	Doc   int64  `json:"doc"`   // 文档id(IIDX为MID, SDVX为曲目id)
+	Field string `json:"field"` // 命中的字段(title/nick/alias...)
+	Text  string `json:"text"`  // 命中的原文
+	Key   string `json:"key"`   // 实际被索引的文本(通常同原文, 拼音/罗马音等派生字段为派生结果)
+}
+
+type entry struct {
+	Hit
+	folded string // 归一化后的文本
+}
+
+// Index 只读倒排索引, 由Builder构建
+// 对归一化后的文本建立1-gram和2-gram倒排表, 子串/前缀/忽略大小写查询都不需要全表扫描
+type Index struct {
+	fold    func(string) string
+	entries []entry
+
+	exact  map[string][]int32 // 原文 -> 条目
+	folded map[string][]int32 // 归一化文本 -> 条目
+	grams  map[string][]int32 // 归一化文本的1/2-gram -> 条目(升序)
+	keys   []string           // 排序后的归一化文本, 前缀查找用
+}
+
+// Builder 索引构建器
+type Builder struct {
+	idx *Index
+}
+
+// NewBuilder 新建构建器
+// fold 归一化函数, 为空时使用strings.ToLower
+func NewBuilder(fold func(string) string) *Builder {
+	if fold == nil {
+		fold = strings.ToLower
+	}
+
+	return &Builder{idx: &Index{
+		fold:   fold,
+		exact:  make(map[string][]int32),
+		folded: make(map[string][]int32),
+		grams:  make(map[string][]int32),
+	}}
+}
+
+// Add 添加一条文本, 空文本忽略
+func (b *Builder) Add(doc int64, field, text string) {
+	b.AddKey(doc, field, text, text)
+}
+
+// AddKey 以key建立索引, 命中时返回原文text
+// 用于拼音/罗马音等派生字段
+func (b *Builder) AddKey(doc int64, field, text, key string) {
+	if key == "" {
+		return
+	}
+
+	idx := b.idx
+	pos := int32(len(idx.entries))
+	folded := idx.fold(key)
+
+	idx.entries = append(idx.entries, entry{Hit: Hit{Doc: doc, Field: field, Text: text, Key: key}, folded: folded})
+	idx.exact[key] = append(idx.exact[key], pos)
+	if _, ok := idx.folded[folded]; !ok {
+		idx.keys = append(idx.keys, folded)
+	}
+	idx.folded[folded] = append(idx.folded[folded], pos)
+
+	for _, gram := range grams(folded) {
+		list := idx.grams[gram]
+		// 同一条目内重复的gram只记一次
+		if n := len(list); n == 0 || list[n-1] != pos {
+			idx.grams[gram] = append(list, pos)
+		}
+	}
+}
+
+// Build 完成构建, 之后不可再Add
+func (b *Builder) Build() *Index {
+	idx := b.idx
+	sort.Strings(idx.keys)
+	b.idx = nil
+	return idx
+}
+
+// grams 文本的全部1-gram和2-gram
+func grams(s string) []string {
+	runes := []rune(s)
+	result := make([]string, 0, len(runes)*2)
+	for i := range runes {
+		result = append(result, string(runes[i]))
+		if i+1 < len(runes) {
+			result = append(result, string(runes[i:i+2]))
+		}
+	}
+	return result
+}
+
+// Len 条目数
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return len(idx.entries)
+}
+
+// Fold 使用索引的归一化函数处理文本
+func (idx *Index) Fold(s string) string {
+	if idx == nil {
+		return strings.ToLower(s)
+	}
+	return idx.fold(s)
+}
+
+// Exact 原文(key)完全一致
+func (idx *Index) Exact(query string, fields ...string) []Hit {
+	if idx == nil {
+		return nil
+	}
+	return idx.collect(idx.exact[query], fields, nil)
+}
+
+// ExactFold 归一化后完全一致(忽略大小写等)
+func (idx *Index) ExactFold(query string, fields ...string) []Hit {
+	if idx == nil {
+		return nil
+	}
+	return idx.collect(idx.folded[idx.fold(query)], fields, nil)
+}
+
+// Prefix 归一化后前缀匹配
+func (idx *Index) Prefix(query string, fields ...string) []Hit {
+	if idx == nil {
+		return nil
+	}
+
+	folded := idx.fold(query)
+	start := sort.SearchStrings(idx.keys, folded)
+
+	positions := make([]int32, 0)
+	for i := start; i < len(idx.keys) && strings.HasPrefix(idx.keys[i], folded); i++ {
+		positions = append(positions, idx.folded[idx.keys[i]]...)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+
+	return idx.collect(positions, fields, nil)
+}
+
+// Contains 原文(key)包含query(区分大小写)
+func (idx *Index) Contains(query string, fields ...string) []Hit {
+	if idx == nil {
+		return nil
+	}
+	return idx.collect(idx.candidates(idx.fold(query)), fields, func(e *entry) bool {
+		return strings.Contains(e.Key, query)
+	})
+}
+
+// ContainsFold 归一化后包含query(忽略大小写等)
+func (idx *Index) ContainsFold(query string, fields ...string) []Hit {
+	if idx == nil {
+		return nil
+	}
+	folded := idx.fold(query)
+	lower := strings.ToLower(query)
+	return idx.collect(idx.candidates(folded), fields, func(e *entry) bool {
+		// 全是标点的查询归一化后保留了标点, 只能和原文比较
+		return strings.Contains(e.folded, folded) || strings.Contains(strings.ToLower(e.Key), lower)
+	})
+}
+
+// Scan 遍历条目(原文和归一化文本), fn返回false时停止
+// 用于相似度等无法走倒排的匹配
+func (idx *Index) Scan(fn func(hit Hit, folded string) bool, fields ...string) {
+	if idx == nil {
+		return
+	}
+	for i := range idx.entries {
+		e := &idx.entries[i]
+		if !matchField(e.Field, fields) {
+			continue
+		}
+		if !fn(e.Hit, e.folded) {
+			return
+		}
+	}
+}
+
+// candidates 通过gram倒排表求交集得到候选条目(升序)
+// 查询中有索引里没有的含标点/空白的gram时, 可能是归一化去掉了条目中的标点而保留了查询中的标点(如"!!!"),
+// 这时返回全部条目, 由调用方逐条校验
+func (idx *Index) candidates(folded string) []int32 {
+	if folded == "" {
+		return idx.all()
+	}
+
+	runes := []rune(folded)
+	keys := make([]string, 0, len(runes))
+	if len(runes) == 1 {
+		keys = append(keys, folded)
+	} else {
+		for i := 0; i+1 < len(runes); i++ {
+			keys = append(keys, string(runes[i:i+2]))
+		}
+	}
+
+	lists := make([][]int32, 0, len(keys))
+	for _, key := range keys {
+		list, ok := idx.grams[key]
+		if !ok {
+			if strings.IndexFunc(key, stripped) >= 0 {
+				return idx.all()
+			}
+			return nil
+		}
+		lists = append(lists, list)
+	}
+
+	// 从最短的表开始求交集
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersect(result, list)
+		if len(result) == 0 {
+			return nil
+		}
+	}
+
+	return result
+}
+
+// all 全部条目(升序)
+func (idx *Index) all() []int32 {
+	all := make([]int32, len(idx.entries))
+	for i := range all {
+		all[i] = int32(i)
+	}
+	return all
+}
+
+// stripped 归一化时可能被去掉的字符(标点/符号/空白)
+func stripped(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r)
+}
+
+// collect 按字段和校验函数过滤条目
+func (idx *Index) collect(positions []int32, fields []string, verify func(e *entry) bool) []Hit {
+	hits := make([]Hit, 0, len(positions))
+	for _, pos := range positions {
+		e := &idx.entries[pos]
+		if !matchField(e.Field, fields) {
+			continue
+		}
+		if verify != nil && !verify(e) {
+			continue
+		}
+		hits = append(hits, e.Hit)
+	}
+	return hits
+}
+
+func matchField(field string, fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// intersect 两个升序表求交集
+func intersect(a, b []int32) []int32 {
+	result := make([]int32, 0, minInt(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testIndex() *Index {
+	builder := NewBuilder(nil)
+	builder.Add(1, "title", "HYPERNOVA")
+	builder.Add(2, "title", "Hyper Drive")
+	builder.Add(3, "title", "晴天Bon Voyage")
+	builder.Add(3, "alias", "晴天")
+	builder.Add(4, "alias", "晕船")
+	return builder.Build()
+}
+
+func docs(hits []Hit) []int64 {
+	result := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		result = append(result, hit.Doc)
+	}
+	return result
+}
+
+func TestIndexLookup(t *testing.T) {
+	idx := testIndex()
+
+	cases := []struct {
+		name string
+		hits []Hit
+		want string
+	}{
+		{"exact", idx.Exact("HYPERNOVA"), "[1]"},
+		{"exact case", idx.Exact("hypernova"), "[]"},
+		{"exact fold", idx.ExactFold("hypernova"), "[1]"},
+		{"prefix", idx.Prefix("hyper"), "[1 2]"},
+		{"contains", idx.Contains("Bon"), "[3]"},
+		{"contains case", idx.Contains("bon"), "[]"},
+		{"contains fold", idx.ContainsFold("PER"), "[1 2]"},
+		{"single rune", idx.ContainsFold("晴"), "[3 3]"},
+		{"field", idx.ContainsFold("晴", "alias"), "[3]"},
+		{"miss", idx.ContainsFold("xyz"), "[]"},
+		{"cjk", idx.Exact("晕船"), "[4]"},
+	}
+
+	for _, c := range cases {
+		if got := fmt.Sprint(docs(c.hits)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIndexPunctuationQuery(t *testing.T) {
+	builder := NewBuilder(NewNormalizer(DefaultNormalizeOptions()))
+	builder.Add(1, "title", "!!!Chaos Time!!!")
+	builder.Add(2, "title", "Hello, World")
+	builder.Add(3, "title", "HYPERNOVA")
+	idx := builder.Build()
+
+	// 只有标点的查询归一化后保留了标点, 条目中的标点已被去掉, 要逐条和原文比较
+	cases := []struct {
+		name string
+		hits []Hit
+		want string
+	}{
+		{"contains", idx.Contains("!!!"), "[1]"},
+		{"contains comma", idx.Contains(", "), "[2]"},
+		{"contains fold", idx.ContainsFold("!!!"), "[1]"},
+		{"miss", idx.Contains("?!"), "[]"},
+		{"letters", idx.ContainsFold("chaos"), "[1]"},
+	}
+
+	for _, c := range cases {
+		if got := fmt.Sprint(docs(c.hits)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+// benchCorpus 生成接近真实规模的曲名/外号
+func benchCorpus(n int) []string {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"Hyper", "Nova", "Drive", "Bon", "Voyage", "神様", "いかさま", "晕船", "Life", "Game", "Message", "Everlasting", "Gravity", "Wars", "晴天", "Σ", "ALiCE", "Lunatic"}
+
+	corpus := make([]string, n)
+	for i := range corpus {
+		parts := make([]string, 1+r.Intn(4))
+		for j := range parts {
+			parts[j] = words[r.Intn(len(words))]
+		}
+		corpus[i] = fmt.Sprintf("%s %d", strings.Join(parts, " "), i)
+	}
+	return corpus
+}
+
+// linearContainsFold 改造前的实现: 每次查询遍历全部文本并逐个转小写
+func linearContainsFold(corpus []string, query string) []int {
+	result := make([]int, 0)
+	for i, text := range corpus {
+		if strings.Contains(strings.ToLower(text), strings.ToLower(query)) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func BenchmarkContainsFold(b *testing.B) {
+	corpus := benchCorpus(20000)
+
+	builder := NewBuilder(nil)
+	for i, text := range corpus {
+		builder.Add(int64(i), "title", text)
+	}
+	idx := builder.Build()
+
+	for _, query := range []string{"nova drive", "晕船 life", "1234"} {
+		if len(idx.ContainsFold(query)) != len(linearContainsFold(corpus, query)) {
+			b.Fatalf("index and linear scan disagree on %q", query)
+		}
+
+		b.Run("index/"+query, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				idx.ContainsFold(query)
+			}
+		})
+
+		b.Run("linear/"+query, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				linearContainsFold(corpus, query)
+			}
+		})
+	}
+}
+
+func BenchmarkExactFold(b *testing.B) {
+	corpus := benchCorpus(20000)
+
+	builder := NewBuilder(nil)
+	for i, text := range corpus {
+		builder.Add(int64(i), "title", text)
+	}
+	idx := builder.Build()
+	query := strings.ToUpper(corpus[12345])
+
+	b.Run("index", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			idx.ExactFold(query)
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, text := range corpus {
+				if strings.ToLower(text) == strings.ToLower(query) {
+					break
+				}
+			}
+		}
+	})
+}
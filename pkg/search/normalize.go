@@ -0,0 +1,83 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// NormalizeOptions 归一化配置, 每一步都可以单独开关
+type NormalizeOptions struct {
+	NFKC        bool // NFKC兼容归一化(Ⅱ->II, ｱ->ア, ①->1)
+	Width       bool // 全角/半角折叠(Ａ->A, ｶ->カ)
+	Kana        bool // 平假名->片假名
+	Chinese     bool // 繁体->简体(暈船->晕船)
+	Lower       bool // 忽略大小写
+	Punctuation bool // 去除标点和符号
+	Space       bool // 去除空白
+}
+
+// DefaultNormalizeOptions 默认全部开启
+func DefaultNormalizeOptions() NormalizeOptions {
+	return NormalizeOptions{
+		NFKC:        true,
+		Width:       true,
+		Kana:        true,
+		Chinese:     true,
+		Lower:       true,
+		Punctuation: true,
+		Space:       true,
+	}
+}
+
+// NewNormalizer 按配置生成归一化函数, 查询和索引文本使用同一个函数
+// 文本去除标点/空白后为空时(如曲名"!!!"), 退回只做前几步的结果, 避免变成空串
+func NewNormalizer(opts NormalizeOptions) func(string) string {
+	return func(s string) string {
+		if opts.NFKC {
+			s = norm.NFKC.String(s)
+		}
+		if opts.Width {
+			s = width.Fold.String(s)
+		}
+		if opts.Kana {
+			s = strings.Map(foldKana, s)
+		}
+		if opts.Chinese {
+			s = strings.Map(foldChinese, s)
+		}
+		if opts.Lower {
+			s = strings.ToLower(s)
+		}
+
+		if !opts.Punctuation && !opts.Space {
+			return s
+		}
+
+		stripped := strings.Map(func(r rune) rune {
+			if opts.Space && unicode.IsSpace(r) {
+				return -1
+			}
+			if opts.Punctuation && (unicode.IsPunct(r) || unicode.IsSymbol(r)) {
+				return -1
+			}
+			return r
+		}, s)
+		if stripped == "" {
+			return s
+		}
+		return stripped
+	}
+}
+
+// foldKana 平假名转片假名
+func foldKana(r rune) rune {
+	switch {
+	case r >= 'ぁ' && r <= 'ゖ', r == 'ゝ', r == 'ゞ':
+		return r + 0x60
+	default:
+		return r
+	}
+}
@@ -0,0 +1,26 @@
+package search
+
+import "testing"
+
+func TestNormalizer(t *testing.T) {
+	normalize := NewNormalizer(DefaultNormalizeOptions())
+
+	for in, want := range map[string]string{
+		"Ｈｙｐｅｒ　Ｎｏｖａ": "hypernova",
+		"Hyper Nova": "hypernova",
+		"GRADIUSⅡ":   "gradiusii",
+		"ｶﾐｻﾏ":       "カミサマ",
+		"かみさま":       "カミサマ",
+		"!!!":        "!!!",
+		"晕船～":        "晕船",
+	} {
+		if got := normalize(in); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	onlyLower := NewNormalizer(NormalizeOptions{Lower: true})
+	if got := onlyLower("Ｈｙｐｅｒ Nova"); got != "ｈｙｐｅｒ nova" {
+		t.Errorf("unexpected %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinArgs 无声调全拼, 多音字取常用读音, 非汉字原样保留
+var pinyinArgs = func() pinyin.Args {
+	args := pinyin.NewArgs()
+	args.Fallback = func(r rune, _ pinyin.Args) []string {
+		return []string{string(r)}
+	}
+	return args
+}()
+
+// HasHan 是否包含汉字
+func HasHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pinyin 汉字转全拼和首字母(拼音表随程序打包, 不需要联网)
+// 例: 晕船 -> yunchuan, yc; 非汉字部分在两种结果里都原样保留
+func Pinyin(s string) (full, initials string) {
+	var fullBuilder, initialsBuilder strings.Builder
+
+	for _, r := range s {
+		syllables := pinyin.SinglePinyin(r, pinyinArgs)
+		if len(syllables) == 0 {
+			continue
+		}
+		syllable := syllables[0]
+
+		fullBuilder.WriteString(syllable)
+		if unicode.Is(unicode.Han, r) {
+			initialsBuilder.WriteString(syllable[:1])
+		} else {
+			initialsBuilder.WriteString(syllable)
+		}
+	}
+
+	return fullBuilder.String(), initialsBuilder.String()
+}
+
+// 拼音派生字段
+const (
+	FieldPinyin   = "pinyin"   // 全拼
+	FieldInitials = "initials" // 首字母
+)
+
+// AddPinyin 为含汉字的文本添加全拼和首字母索引, 命中时返回原文
+func (b *Builder) AddPinyin(doc int64, text string) {
+	if !HasHan(text) {
+		return
+	}
+
+	full, initials := Pinyin(text)
+	b.AddKey(doc, FieldPinyin, text, full)
+	b.AddKey(doc, FieldInitials, text, initials)
+}
+
+// MatchPinyin 拼音匹配: 全拼或首字母完全一致, 都没有时按全拼前缀匹配
+// 查询本身含汉字时不走拼音
+func (idx *Index) MatchPinyin(query string) []Hit {
+	if HasHan(query) {
+		return nil
+	}
+
+	if hits := idx.ExactFold(query); len(hits) != 0 {
+		return hits
+	}
+
+	// 太短的前缀没有区分度
+	if len([]rune(idx.Fold(query))) < 2 {
+		return nil
+	}
+	return idx.Prefix(query, FieldPinyin)
+}
@@ -0,0 +1,17 @@
+package search
+
+import "testing"
+
+func TestPinyin(t *testing.T) {
+	for in, want := range map[string][2]string{
+		"晕船":    {"yunchuan", "yc"},
+		"BI晕船":  {"BIyunchuan", "BIyc"},
+		"海神王":   {"haishenwang", "hsw"},
+		"ALiCE": {"ALiCE", "ALiCE"},
+	} {
+		full, initials := Pinyin(in)
+		if full != want[0] || initials != want[1] {
+			t.Errorf("Pinyin(%q) = %q, %q, want %q", in, full, initials, want)
+		}
+	}
+}
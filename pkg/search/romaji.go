@@ -0,0 +1,131 @@
+package search
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// kanaRomaji 片假名 -> 平文式(Hepburn)罗马音
+var kanaRomaji = map[string]string{
+	"ア": "a", "イ": "i", "ウ": "u", "エ": "e", "オ": "o",
+	"カ": "ka", "キ": "ki", "ク": "ku", "ケ": "ke", "コ": "ko",
+	"サ": "sa", "シ": "shi", "ス": "su", "セ": "se", "ソ": "so",
+	"タ": "ta", "チ": "chi", "ツ": "tsu", "テ": "te", "ト": "to",
+	"ナ": "na", "ニ": "ni", "ヌ": "nu", "ネ": "ne", "ノ": "no",
+	"ハ": "ha", "ヒ": "hi", "フ": "fu", "ヘ": "he", "ホ": "ho",
+	"マ": "ma", "ミ": "mi", "ム": "mu", "メ": "me", "モ": "mo",
+	"ヤ": "ya", "ユ": "yu", "ヨ": "yo",
+	"ラ": "ra", "リ": "ri", "ル": "ru", "レ": "re", "ロ": "ro",
+	"ワ": "wa", "ヰ": "i", "ヱ": "e", "ヲ": "o", "ン": "n",
+	"ガ": "ga", "ギ": "gi", "グ": "gu", "ゲ": "ge", "ゴ": "go",
+	"ザ": "za", "ジ": "ji", "ズ": "zu", "ゼ": "ze", "ゾ": "zo",
+	"ダ": "da", "ヂ": "ji", "ヅ": "zu", "デ": "de", "ド": "do",
+	"バ": "ba", "ビ": "bi", "ブ": "bu", "ベ": "be", "ボ": "bo",
+	"パ": "pa", "ピ": "pi", "プ": "pu", "ペ": "pe", "ポ": "po",
+	"ヴ": "vu",
+	"ァ": "a", "ィ": "i", "ゥ": "u", "ェ": "e", "ォ": "o",
+	"ャ": "ya", "ュ": "yu", "ョ": "yo", "ヮ": "wa",
+
+	// 拗音
+	"キャ": "kya", "キュ": "kyu", "キョ": "kyo",
+	"シャ": "sha", "シュ": "shu", "ショ": "sho",
+	"チャ": "cha", "チュ": "chu", "チョ": "cho",
+	"ニャ": "nya", "ニュ": "nyu", "ニョ": "nyo",
+	"ヒャ": "hya", "ヒュ": "hyu", "ヒョ": "hyo",
+	"ミャ": "mya", "ミュ": "myu", "ミョ": "myo",
+	"リャ": "rya", "リュ": "ryu", "リョ": "ryo",
+	"ギャ": "gya", "ギュ": "gyu", "ギョ": "gyo",
+	"ジャ": "ja", "ジュ": "ju", "ジョ": "jo",
+	"ヂャ": "ja", "ヂュ": "ju", "ヂョ": "jo",
+	"ビャ": "bya", "ビュ": "byu", "ビョ": "byo",
+	"ピャ": "pya", "ピュ": "pyu", "ピョ": "pyo",
+
+	// 外来语
+	"シェ": "she", "ジェ": "je", "チェ": "che",
+	"ティ": "ti", "ディ": "di", "トゥ": "tu", "ドゥ": "du", "デュ": "dyu",
+	"ファ": "fa", "フィ": "fi", "フェ": "fe", "フォ": "fo", "フュ": "fyu",
+	"ウィ": "wi", "ウェ": "we", "ウォ": "wo",
+	"ヴァ": "va", "ヴィ": "vi", "ヴェ": "ve", "ヴォ": "vo",
+	"ツァ": "tsa", "ツィ": "tsi", "ツェ": "tse", "ツォ": "tso",
+	"イェ": "ye", "クァ": "kwa", "グァ": "gwa",
+}
+
+// Romaji 假名读音转平文式罗马音(不带长音符号, 长音"ー"省略)
+// 平假名/半角片假名会先转成全角片假名, 非假名字符原样保留
+func Romaji(kana string) string {
+	runes := []rune(strings.Map(foldKana, norm.NFKC.String(kana)))
+
+	var builder strings.Builder
+	sokuon := false // 促音ッ, 双写下一个辅音
+
+	for i := 0; i < len(runes); i++ {
+		var syllable string
+		if i+1 < len(runes) {
+			if s, ok := kanaRomaji[string(runes[i:i+2])]; ok {
+				syllable = s
+				i++
+			}
+		}
+		if syllable == "" {
+			if runes[i] == 'ッ' {
+				sokuon = true
+				continue
+			}
+			if runes[i] == 'ー' {
+				continue
+			}
+
+			s, ok := kanaRomaji[string(runes[i])]
+			if !ok {
+				sokuon = false
+				builder.WriteRune(runes[i])
+				continue
+			}
+			syllable = s
+		}
+
+		if sokuon {
+			if strings.HasPrefix(syllable, "ch") {
+				builder.WriteByte('t')
+			} else if c := syllable[0]; !strings.ContainsRune("aiueon", rune(c)) {
+				builder.WriteByte(c)
+			}
+			sokuon = false
+		}
+
+		builder.WriteString(syllable)
+	}
+
+	return builder.String()
+}
+
+// HasKana 是否包含假名
+func HasKana(s string) bool {
+	for _, r := range s {
+		if (r >= 'ぁ' && r <= 'ゖ') || (r >= 'ァ' && r <= 'ヺ') || (r >= 'ｦ' && r <= 'ﾝ') {
+			return true
+		}
+	}
+	return false
+}
+
+// AddRomaji 为含假名的文本添加罗马音索引, 命中时返回原文
+// 例: カミサマノイタズラ -> kamisamanoitazura
+func (b *Builder) AddRomaji(doc int64, field, text string) {
+	if !HasKana(text) {
+		return
+	}
+
+	b.AddKey(doc, field, text, Romaji(text))
+}
+
+// MatchRomaji 罗马音匹配: 查询中的假名先转成罗马音, 再按归一化后包含匹配
+// 查询本身含汉字时不走罗马音
+func (idx *Index) MatchRomaji(query string, fields ...string) []Hit {
+	if HasHan(query) {
+		return nil
+	}
+
+	return idx.ContainsFold(Romaji(query), fields...)
+}
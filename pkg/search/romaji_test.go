@@ -0,0 +1,20 @@
+package search
+
+import "testing"
+
+func TestRomaji(t *testing.T) {
+	for in, want := range map[string]string{
+		"カミサマノイタズラ":      "kamisamanoitazura",
+		"イカサマライフゲイム":     "ikasamaraifugeimu",
+		"ハイパーノヴァ":        "haipanova",
+		"エバーラスティングメッセージ": "ebarasutingumesseji",
+		"ｲｶｻﾏ":           "ikasama",
+		"まっちゃ":           "matcha",
+		"ジャンプ":           "janpu",
+		"晴天ボン":           "晴天bon",
+	} {
+		if got := Romaji(in); got != want {
+			t.Errorf("Romaji(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
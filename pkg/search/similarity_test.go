@@ -0,0 +1,15 @@
+package search
+
+import "testing"
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity("晕舡", "晕船"); s != 0.5 {
+		t.Errorf("unexpected similarity %v", s)
+	}
+	if s := Similarity("hyper nova", "hypernova"); s != 0.9 {
+		t.Errorf("unexpected similarity %v", s)
+	}
+	if s := Similarity("", ""); s != 1 {
+		t.Errorf("unexpected similarity %v", s)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"finder/pkg/util/safefile"
+)
+
+// JSONStore 整文件json存储, 每次变更重写整个文件
+// 写入走临时文件+原子重命名, 可选保留带时间戳的备份
+type JSONStore struct {
+	nickPath  string
+	aliasPath string
+	backups   int // 每个文件保留的备份份数, 0不备份
+}
+
+// NewJSON 新建json存储
+func NewJSON(nickPath, aliasPath string) *JSONStore {
+	return &JSONStore{nickPath: nickPath, aliasPath: aliasPath}
+}
+
+// WithBackups 设置每个文件保留的备份份数
+func (s *JSONStore) WithBackups(keep int) *JSONStore {
+	s.backups = keep
+	return s
+}
+
+// Recover 检查外号和别名文件, 损坏时从最新的可用备份恢复
+// 返回 文件 -> 用于恢复的备份
+func (s *JSONStore) Recover() (map[string]string, error) {
+	recovered := make(map[string]string)
+
+	checks := map[string]func([]byte) error{
+		s.nickPath: func(data []byte) error {
+			return json.Unmarshal(data, &map[string]uint{})
+		},
+		s.aliasPath: func(data []byte) error {
+			return json.Unmarshal(data, &map[string][]string{})
+		},
+	}
+
+	for path, valid := range checks {
+		if path == "" {
+			continue
+		}
+
+		backupPath, err := safefile.Recover(path, valid)
+		if err != nil {
+			return recovered, err
+		}
+		if backupPath != "" {
+			recovered[path] = backupPath
+		}
+	}
+
+	return recovered, nil
+}
+
+// LoadNicks 读取外号
+func (s *JSONStore) LoadNicks() (map[string]uint, error) {
+	jsonBytes, err := os.ReadFile(s.nickPath)
+	if err != nil {
+		return nil, err
+	}
+
+	nicks := make(map[string]uint)
+	if err := json.Unmarshal(jsonBytes, &nicks); err != nil {
+		return nil, err
+	}
+
+	return nicks, nil
+}
+
+// SaveNick 写入外号(整表重写)
+func (s *JSONStore) SaveNick(nicks map[string]uint, _ string) error {
+	bytes, err := json.Marshal(nicks)
+	if err != nil {
+		return err
+	}
+
+	return safefile.WriteFile(s.nickPath, bytes, 0755, s.backups)
+}
+
+// LoadAliases 读取别名
+func (s *JSONStore) LoadAliases() (map[string][]string, error) {
+	fileContent, err := os.ReadFile(s.aliasPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	aliases := make(map[string][]string)
+	if err := json.Unmarshal(fileContent, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	return aliases, nil
+}
+
+// SaveAlias 写入别名(整表重写)
+func (s *JSONStore) SaveAlias(aliases map[string][]string, _, _ string) error {
+	// 将别名数据编码为 JSON 格式
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+
+	// 写入文件
+	if err := safefile.WriteFile(s.aliasPath, data, 0644, s.backups); err != nil {
+		return fmt.Errorf("unable to write file: %v", err)
+	}
+
+	return nil
+}
+
+// Close json存储无需关闭
+func (s *JSONStore) Close() error {
+	return nil
+}
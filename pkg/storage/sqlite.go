@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS iidx_nick (
+	nick TEXT PRIMARY KEY,
+	mid  INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS sdvx_alias (
+	seq   INTEGER PRIMARY KEY AUTOINCREMENT,
+	sid   TEXT NOT NULL,
+	alias TEXT NOT NULL,
+	UNIQUE (sid, alias)
+);
+CREATE INDEX IF NOT EXISTS sdvx_alias_sid ON sdvx_alias (sid);
+`
+
+// metaJSONMigrated json已导入标记
+const metaJSONMigrated = "json_migrated"
+
+// SQLiteStore sqlite存储, 每次变更只写一行
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite 打开(不存在则创建)sqlite数据库
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// MigrateJSON 从json文件一次性导入外号和别名, 已导入过则跳过
+// json文件不存在时视为空
+func (s *SQLiteStore) MigrateJSON(nickPath, aliasPath string) error {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, metaJSONMigrated).Scan(&value)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	src := NewJSON(nickPath, aliasPath)
+
+	nicks, err := src.LoadNicks()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	aliases, err := src.LoadAliases()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for nick, mid := range nicks {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO iidx_nick (nick, mid) VALUES (?, ?)`, nick, mid); err != nil {
+			return err
+		}
+	}
+
+	for sid, aliasList := range aliases {
+		for _, alias := range aliasList {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO sdvx_alias (sid, alias) VALUES (?, ?)`, sid, alias); err != nil {
+				return err
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("%d nicks, %d alias ids", len(nicks), len(aliases))
+	if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)`, metaJSONMigrated, summary); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LoadNicks 读取外号
+func (s *SQLiteStore) LoadNicks() (map[string]uint, error) {
+	rows, err := s.db.Query(`SELECT nick, mid FROM iidx_nick`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nicks := make(map[string]uint)
+	for rows.Next() {
+		var nick string
+		var mid uint
+		if err := rows.Scan(&nick, &mid); err != nil {
+			return nil, err
+		}
+		nicks[nick] = mid
+	}
+
+	return nicks, rows.Err()
+}
+
+// SaveNick 写入或删除一个外号
+func (s *SQLiteStore) SaveNick(nicks map[string]uint, nick string) error {
+	if mid, ok := nicks[nick]; ok {
+		_, err := s.db.Exec(`INSERT OR REPLACE INTO iidx_nick (nick, mid) VALUES (?, ?)`, nick, mid)
+		return err
+	}
+
+	_, err := s.db.Exec(`DELETE FROM iidx_nick WHERE nick = ?`, nick)
+	return err
+}
+
+// LoadAliases 读取别名, 同一曲目内保持添加顺序
+func (s *SQLiteStore) LoadAliases() (map[string][]string, error) {
+	rows, err := s.db.Query(`SELECT sid, alias FROM sdvx_alias ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := make(map[string][]string)
+	for rows.Next() {
+		var sid, alias string
+		if err := rows.Scan(&sid, &alias); err != nil {
+			return nil, err
+		}
+		aliases[sid] = append(aliases[sid], alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// SaveAlias 写入或删除一个别名
+func (s *SQLiteStore) SaveAlias(aliases map[string][]string, sid, alias string) error {
+	if contains(aliases[sid], alias) {
+		_, err := s.db.Exec(`INSERT OR IGNORE INTO sdvx_alias (sid, alias) VALUES (?, ?)`, sid, alias)
+		return err
+	}
+
+	_, err := s.db.Exec(`DELETE FROM sdvx_alias WHERE sid = ? AND alias = ?`, sid, alias)
+	return err
+}
+
+// Close 关闭数据库
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
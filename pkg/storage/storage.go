@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+存储包
+IIDX外号和SDVX别名的持久化, 支持json和sqlite两种后端
+*/
+
+const (
+	DriverJSON   = "json"
+	DriverSQLite = "sqlite"
+)
+
+// NickStore IIDX外号存储
+type NickStore interface {
+	// LoadNicks 读取全部外号
+	LoadNicks() (map[string]uint, error)
+	// SaveNick 保存一个外号的变更
+	// nicks为变更后的完整外号表, nick在表中则写入, 不在则删除
+	SaveNick(nicks map[string]uint, nick string) error
+}
+
+// AliasStore SDVX别名存储
+type AliasStore interface {
+	// LoadAliases 读取全部别名(曲目id -> 别名列表)
+	LoadAliases() (map[string][]string, error)
+	// SaveAlias 保存一个别名的变更
+	// aliases为变更后的完整别名表, alias在aliases[sid]中则写入, 不在则删除
+	SaveAlias(aliases map[string][]string, sid, alias string) error
+}
+
+// Store 外号和别名的存储后端
+type Store interface {
+	NickStore
+	AliasStore
+	Close() error
+}
+
+// Options 存储配置
+type Options struct {
+	NickPath  string         // 外号json文件(music_nick.json)
+	AliasPath string         // 别名json文件(aliases.json)
+	DBPath    string         // sqlite数据库文件
+	Backups   int            // json文件保留的备份份数, 0不备份
+	Logln     func(v ...any) // 日志, 可为空
+}
+
+// Open 按驱动名打开存储
+// 打开前会检查json文件, 损坏时从最新的可用备份恢复
+// sqlite首次打开时会从json文件一次性导入已有的外号和别名
+func Open(driver string, opts Options) (Store, error) {
+	jsonStore := NewJSON(opts.NickPath, opts.AliasPath).WithBackups(opts.Backups)
+
+	recovered, err := jsonStore.Recover()
+	for path, backupPath := range recovered {
+		if opts.Logln != nil {
+			opts.Logln("recovered", path, "from backup", backupPath)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(driver) {
+	case "", DriverJSON:
+		return jsonStore, nil
+	case DriverSQLite:
+		store, err := OpenSQLite(opts.DBPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.MigrateJSON(opts.NickPath, opts.AliasPath); err != nil {
+			_ = store.Close()
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", driver)
+	}
+}
+
+// contains 别名列表中是否有alias
+func contains(aliasList []string, alias string) bool {
+	for _, a := range aliasList {
+		if a == alias {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteMigrateJSON(t *testing.T) {
+	dir := t.TempDir()
+	nickPath := filepath.Join(dir, "music_nick.json")
+	aliasPath := filepath.Join(dir, "aliases.json")
+	dbPath := filepath.Join(dir, "finder.db")
+
+	if err := os.WriteFile(nickPath, []byte(`{"摇滚大房子":30053}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(aliasPath, []byte(`{"2":["晕船","ikasama"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(DriverSQLite, Options{NickPath: nickPath, AliasPath: aliasPath, DBPath: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nicks, err := store.LoadNicks()
+	if err != nil || nicks["摇滚大房子"] != 30053 {
+		t.Fatalf("nicks not migrated: %v %v", nicks, err)
+	}
+
+	aliases, err := store.LoadAliases()
+	if err != nil || !reflect.DeepEqual(aliases["2"], []string{"晕船", "ikasama"}) {
+		t.Fatalf("aliases not migrated: %v %v", aliases, err)
+	}
+
+	// 变更只写一行
+	aliases["2"] = []string{"ikasama", "yc"}
+	if err := store.SaveAlias(aliases, "2", "晕船"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveAlias(aliases, "2", "yc"); err != nil {
+		t.Fatal(err)
+	}
+	delete(nicks, "摇滚大房子")
+	if err := store.SaveNick(nicks, "摇滚大房子"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已导入过, 再次打开不会重复导入json
+	store, err = Open(DriverSQLite, Options{NickPath: nickPath, AliasPath: aliasPath, DBPath: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if nicks, _ := store.LoadNicks(); len(nicks) != 0 {
+		t.Fatalf("json migrated twice: %v", nicks)
+	}
+	if aliases, _ := store.LoadAliases(); !reflect.DeepEqual(aliases["2"], []string{"ikasama", "yc"}) {
+		t.Fatalf("unexpected aliases: %v", aliases)
+	}
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	if _, err := Open("redis", Options{}); err == nil {
+		t.Fatal("expected unknown driver error")
+	}
+}
+
+func TestSQLiteDuplicatedAliasAcrossSongs(t *testing.T) {
+	dir := t.TempDir()
+	aliasPath := filepath.Join(dir, "aliases.json")
+	dbPath := filepath.Join(dir, "finder.db")
+
+	// json中同一个别名可以属于不同曲目
+	if err := os.WriteFile(aliasPath, []byte(`{"2":["晕船","yc"],"5":["晕船"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(DriverSQLite, Options{NickPath: filepath.Join(dir, "music_nick.json"), AliasPath: aliasPath, DBPath: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	aliases, err := store.LoadAliases()
+	if err != nil || !reflect.DeepEqual(aliases["2"], []string{"晕船", "yc"}) || !reflect.DeepEqual(aliases["5"], []string{"晕船"}) {
+		t.Fatalf("duplicated alias dropped in migration: %v %v", aliases, err)
+	}
+
+	// 另一首曲目添加已有的别名不会把它从原曲目移走
+	aliases["6"] = []string{"yc"}
+	if err := store.SaveAlias(aliases, "6", "yc"); err != nil {
+		t.Fatal(err)
+	}
+	aliases, _ = store.LoadAliases()
+	if !reflect.DeepEqual(aliases["2"], []string{"晕船", "yc"}) || !reflect.DeepEqual(aliases["6"], []string{"yc"}) {
+		t.Fatalf("alias moved between songs: %v", aliases)
+	}
+}
@@ -0,0 +1,186 @@
+package safefile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+工具包
+安全写文件: 临时文件 + fsync + 原子重命名, 并保留带时间戳的备份
+*/
+
+// backupLayout 备份文件名中的时间戳格式
+const backupLayout = "20060102-150405.000"
+
+// backupSuffix 备份文件后缀
+const backupSuffix = ".bak"
+
+// WriteFile 原子写文件
+// 先写同目录下的临时文件并fsync, 再重命名覆盖目标文件
+// keep > 0 时覆盖前把旧文件留作备份, 最多保留keep份
+func WriteFile(path string, data []byte, perm os.FileMode, keep int) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// 成功时临时文件已被重命名, 这里只清理失败的情况
+		_ = os.Remove(tmpName)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+
+	if keep > 0 {
+		if err := backup(path, keep); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// Backups 按时间从新到旧列出path的备份文件
+func Backups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*" + backupSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]string, 0, len(matches))
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, path+"."), backupSuffix)
+		if _, err := time.Parse(backupLayout, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+
+	// 时间戳定长, 字典序即时间序
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// Recover 检查path是否可用, 不可用(不存在或valid校验失败)时从最新的可用备份恢复
+// 返回用于恢复的备份文件, 无需恢复时为空
+func Recover(path string, valid func(data []byte) error) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && valid(data) == nil {
+		return "", nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, backupPath := range backups {
+		data, err := os.ReadFile(backupPath)
+		if err != nil || valid(data) != nil {
+			continue
+		}
+
+		if err := WriteFile(path, data, 0644, 0); err != nil {
+			return "", err
+		}
+		return backupPath, nil
+	}
+
+	if len(backups) == 0 {
+		return "", nil
+	}
+	return "", fmt.Errorf("%s is corrupted and no valid backup found", path)
+}
+
+// backup 把当前文件留作备份并清理多余的旧备份
+func backup(path string, keep int) error {
+	backupPath := path + "." + time.Now().Format(backupLayout) + backupSuffix
+
+	if err := os.Link(path, backupPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// 第一次写入, 没有旧文件
+			return nil
+		}
+		if err := copyFile(path, backupPath); err != nil {
+			return err
+		}
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile 硬链接不可用时复制文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir 落盘目录项, 保证重命名在掉电后依然有效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// 部分平台不支持对目录fsync, 忽略该错误
+	_ = d.Sync()
+	return nil
+}
@@ -0,0 +1,55 @@
+package safefile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFileBackupAndRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+
+	for _, content := range []string{`{"v":1}`, `{"v":2}`, `{"v":3}`, `{"v":4}`} {
+		if err := WriteFile(path, []byte(content), 0644, 2); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+
+	// 模拟写坏的文件
+	if err := os.WriteFile(path, []byte(`{"v":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func(data []byte) error {
+		return json.Unmarshal(data, &map[string]int{})
+	}
+
+	from, err := Recover(path, valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != backups[0] {
+		t.Fatalf("expected recover from newest backup %s, got %s", backups[0], from)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != `{"v":3}` {
+		t.Fatalf("unexpected recovered content %s", data)
+	}
+
+	// 完好的文件无需恢复
+	if from, err := Recover(path, valid); err != nil || from != "" {
+		t.Fatalf("unexpected recover %s %v", from, err)
+	}
+}